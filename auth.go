@@ -2,11 +2,17 @@ package wine
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/base64"
+	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
 
+	"github.com/gopub/errors"
 	"github.com/gopub/wine/ctxutil"
+	"github.com/gopub/wine/httpvalue"
 	"github.com/gopub/wine/internal/respond"
 )
 
@@ -16,23 +22,28 @@ func NewBasicAuthHandler(userToPassword map[string]string, realm string) Handler
 		logger.Panic("userToPassword is empty")
 	}
 
-	userToAuthorization := make(map[string]string)
+	userToAuthorization := make(map[string][]byte)
 	for user, password := range userToPassword {
 		if user == "" || password == "" {
 			logger.Panic("Empty user or password")
 		}
 		info := user + ":" + password
-		userToAuthorization[user] = "Basic " + base64.StdEncoding.EncodeToString([]byte(info))
+		userToAuthorization[user] = []byte("Basic " + base64.StdEncoding.EncodeToString([]byte(info)))
 	}
 
 	return func(ctx context.Context, req *Request) Responder {
-		a := req.Authorization()
+		a := []byte(req.Authorization())
+		matched := ""
+		// Compare with all accounts in constant time
 		for user, auth := range userToAuthorization {
-			if auth == a {
-				ctx = ctxutil.WithBasicUser(ctx, user)
-				return Next(ctx, req)
+			if subtle.ConstantTimeCompare(auth, a) == 1 {
+				matched = user
 			}
 		}
+		if matched != "" {
+			ctx = ctxutil.WithBasicUser(ctx, matched)
+			return Next(ctx, req)
+		}
 		return RequireBasicAuth(realm)
 	}
 }
@@ -44,3 +55,171 @@ func RequireBasicAuth(realm string) Responder {
 		w.WriteHeader(http.StatusUnauthorized)
 	})
 }
+
+// BasicAuthOptions defines how to authenticate with UserStore
+type BasicAuthOptions struct {
+	Realm string
+	// MaxFailures is the number of consecutive failures before user or client ip is locked out. Zero means no lockout
+	MaxFailures int
+	// LockoutDuration is how long user or client ip is locked out, default value is 15 minutes
+	LockoutDuration time.Duration
+}
+
+// NewBasicAuthStoreHandler returns a basic auth interceptor which verifies passwords with hashes in store.
+// If options.MaxFailures is set, both the user and client ip are locked out after too many failures,
+// and requests are responded 429 with Retry-After.
+func NewBasicAuthStoreHandler(store UserStore, options *BasicAuthOptions) HandlerFunc {
+	if store == nil {
+		logger.Panic("store is nil")
+	}
+	if options == nil {
+		options = &BasicAuthOptions{}
+	}
+	var l *lockout
+	if options.MaxFailures > 0 {
+		d := options.LockoutDuration
+		if d <= 0 {
+			d = 15 * time.Minute
+		}
+		l = newLockout(options.MaxFailures, d)
+	}
+
+	return func(ctx context.Context, req *Request) Responder {
+		user, password := req.BasicAccount()
+		if user == "" {
+			return RequireBasicAuth(options.Realm)
+		}
+
+		keys := []string{"user:" + user, "ip:" + remoteIP(req.request)}
+		if l != nil {
+			// Count the attempt as a failure before verifying, so that concurrent attempts can't exceed MaxFailures
+			if d := l.reserve(keys...); d > 0 {
+				retryAfter := int64(d/time.Second) + 1
+				return WithHeader(Error(errors.TooManyRequests("Too many failed attempts, retry after %d seconds", retryAfter)),
+					http.Header{httpvalue.RetryAfter: {strconv.FormatInt(retryAfter, 10)}})
+			}
+		}
+
+		ok, err := verifyUser(ctx, store, user, password)
+		if err != nil {
+			return Error(err)
+		}
+		if !ok {
+			return RequireBasicAuth(options.Realm)
+		}
+		if l != nil {
+			l.reset(keys...)
+		}
+		ctx = ctxutil.WithBasicUser(ctx, user)
+		return Next(ctx, req)
+	}
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     string
+)
+
+// verifyUser verifies unknown users with a dummy hash, so that they can't be found out by timing
+func verifyUser(ctx context.Context, store UserStore, user, password string) (bool, error) {
+	hash, err := store.PasswordHash(ctx, user)
+	if err != nil && !errors.IsNotExist(err) {
+		return false, err
+	}
+	if hash == "" {
+		dummyHashOnce.Do(func() {
+			dummyHash, _ = HashPassword(strconv.FormatInt(time.Now().UnixNano(), 10))
+		})
+		VerifyPassword(dummyHash, password)
+		return false, nil
+	}
+	return VerifyPassword(hash, password)
+}
+
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+type lockoutEntry struct {
+	failures    int
+	lockedUntil time.Time
+	updatedAt   time.Time
+}
+
+// lockout counts consecutive failures of keys, e.g. users and ips
+type lockout struct {
+	maxFailures int
+	duration    time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*lockoutEntry
+	purgedAt time.Time
+}
+
+func newLockout(maxFailures int, duration time.Duration) *lockout {
+	return &lockout{
+		maxFailures: maxFailures,
+		duration:    duration,
+		entries:     make(map[string]*lockoutEntry),
+	}
+}
+
+// reserve counts an attempt of keys as a failure unless they are locked out.
+// It returns the longest remaining lockout duration of keys, which is zero if the attempt is reserved.
+func (l *lockout) reserve(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var d time.Duration
+	now := time.Now()
+	for _, k := range keys {
+		if e := l.entries[k]; e != nil {
+			if left := e.lockedUntil.Sub(now); left > d {
+				d = left
+			}
+		}
+	}
+	if d > 0 {
+		return d
+	}
+
+	l.purge(now)
+	for _, k := range keys {
+		e := l.entries[k]
+		if e == nil {
+			e = new(lockoutEntry)
+			l.entries[k] = e
+		}
+		e.failures++
+		e.updatedAt = now
+		if e.failures >= l.maxFailures {
+			e.failures = 0
+			e.lockedUntil = now.Add(l.duration)
+		}
+	}
+	return 0
+}
+
+// reset releases reserved attempts of keys after successful verification
+func (l *lockout) reset(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		delete(l.entries, k)
+	}
+}
+
+// purge removes entries which are neither locked nor updated recently
+func (l *lockout) purge(now time.Time) {
+	if now.Sub(l.purgedAt) < l.duration {
+		return
+	}
+	l.purgedAt = now
+	for k, e := range l.entries {
+		if now.After(e.lockedUntil) && now.Sub(e.updatedAt) > l.duration {
+			delete(l.entries, k)
+		}
+	}
+}
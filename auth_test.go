@@ -0,0 +1,122 @@
+package wine_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/ctxutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	for _, hash := range []func(string) (string, error){wine.HashPassword, wine.HashPasswordArgon2} {
+		h, err := hash("pass:word")
+		require.NoError(t, err)
+		ok, err := wine.VerifyPassword(h, "pass:word")
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = wine.VerifyPassword(h, "password")
+		require.NoError(t, err)
+		require.False(t, ok)
+	}
+	_, err := wine.VerifyPassword("password", "password")
+	require.Error(t, err)
+}
+
+func TestNewBasicAuthStoreHandler(t *testing.T) {
+	tomHash, err := wine.HashPassword("123")
+	require.NoError(t, err)
+	name := filepath.Join(t.TempDir(), ".htpasswd")
+	require.NoError(t, ioutil.WriteFile(name, []byte("# users\ntom:"+tomHash+"\n"), 0600))
+	store, err := wine.LoadHtpasswd(name)
+	require.NoError(t, err)
+
+	server := wine.NewTestServer(t)
+	server.Use(wine.NewBasicAuthStoreHandler(store, &wine.BasicAuthOptions{
+		Realm:           "wine",
+		MaxFailures:     3,
+		LockoutDuration: time.Minute,
+	})).Get("/me", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, ctxutil.GetBasicUser(ctx))
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, user, password string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, url+"/me", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth(user, password)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(b)
+	}
+
+	resp, body := get(t, "tom", "123")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "tom", body)
+
+	resp, _ = get(t, "jim", "123")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.Equal(t, `Basic realm="wine"`, resp.Header.Get("WWW-Authenticate"))
+
+	t.Run("Reload", func(t *testing.T) {
+		jimHash, err := wine.HashPasswordArgon2("456")
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(name, []byte("jim:"+jimHash+"\n"), 0600))
+		require.NoError(t, store.Reload())
+		resp, body := get(t, "jim", "456")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "jim", body)
+		resp, _ = get(t, "tom", "123")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("Lockout", func(t *testing.T) {
+		// The failure of tom has been counted for the ip
+		resp, _ := get(t, "jim", "wrong")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp, _ = get(t, "jim", "wrong")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		resp, _ = get(t, "jim", "456")
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.Equal(t, "60", resp.Header.Get("Retry-After"))
+	})
+
+	t.Run("ConcurrentLockout", func(t *testing.T) {
+		server := wine.NewTestServer(t)
+		server.Use(wine.NewBasicAuthStoreHandler(store, &wine.BasicAuthOptions{MaxFailures: 3})).
+			Get("/me", func(ctx context.Context, req *wine.Request) wine.Responder {
+				return wine.OK
+			})
+		url := server.Run()
+		var wg sync.WaitGroup
+		var unauthorized int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req, _ := http.NewRequest(http.MethodGet, url+"/me", nil)
+				req.SetBasicAuth("jim", "wrong")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					return
+				}
+				resp.Body.Close()
+				if resp.StatusCode == http.StatusUnauthorized {
+					atomic.AddInt32(&unauthorized, 1)
+				}
+			}()
+		}
+		wg.Wait()
+		require.Equal(t, int32(3), unauthorized)
+	})
+}
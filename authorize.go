@@ -0,0 +1,87 @@
+package wine
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/ctxutil"
+	"github.com/gopub/wine/httpvalue"
+)
+
+// Authorizer decides whether the request is allowed to access the endpoint which requires roles or scopes.
+// It's called after interceptors, so that authentication has been done.
+type Authorizer interface {
+	// Authorize returns nil if allowed, otherwise the error is rendered. Errors without status are rendered as 403
+	Authorize(ctx context.Context, req *Request, roles, scopes []string) error
+}
+
+type AuthorizerFunc func(ctx context.Context, req *Request, roles, scopes []string) error
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, req *Request, roles, scopes []string) error {
+	return f(ctx, req, roles, scopes)
+}
+
+// RoleGetter is implemented by users with roles, e.g. *JWTClaims
+type RoleGetter interface {
+	GetRoles() []string
+}
+
+// ScopeGetter is implemented by users or claims with granted scopes, e.g. *JWTClaims
+type ScopeGetter interface {
+	GetScopes() []string
+}
+
+// DefaultAuthorizer reads roles from ctxutil.GetUser, and scopes from ctxutil.GetUser or ctxutil.GetClaims.
+// The user must have one of required roles and all required scopes.
+var DefaultAuthorizer Authorizer = AuthorizerFunc(authorize)
+
+func authorize(ctx context.Context, req *Request, roles, scopes []string) error {
+	user := ctxutil.GetUser(ctx)
+	if user == nil {
+		return errors.Unauthorized("Missing user")
+	}
+	if len(roles) > 0 {
+		rg, _ := user.(RoleGetter)
+		if rg == nil || !containsAny(rg.GetRoles(), roles) {
+			return errors.Forbidden("Require one of roles: %s", strings.Join(roles, ", "))
+		}
+	}
+	if len(scopes) > 0 {
+		sg, _ := user.(ScopeGetter)
+		if sg == nil {
+			sg, _ = ctxutil.GetClaims(ctx).(ScopeGetter)
+		}
+		if sg == nil {
+			return errors.Forbidden("Require scopes: %s", strings.Join(scopes, " "))
+		}
+		granted := sg.GetScopes()
+		for _, s := range scopes {
+			if !containsAny(granted, []string{s}) {
+				return errors.Forbidden("Require scopes: %s", strings.Join(scopes, " "))
+			}
+		}
+	}
+	return nil
+}
+
+// checkPermission authorizes requests to endpoints which require roles or scopes.
+// Sudo requests are always allowed.
+func checkPermission(ctx context.Context, req *Request) Responder {
+	e := req.endpoint
+	if e == nil || ctxutil.IsSudo(ctx) {
+		return nil
+	}
+	roles, scopes := e.RequiredRoles(), e.RequiredScopes()
+	if len(roles) == 0 && len(scopes) == 0 {
+		return nil
+	}
+	if err := e.authorizer().Authorize(ctx, req, roles, scopes); err != nil {
+		if !httpvalue.IsValidStatus(errors.GetCode(err)) {
+			err = errors.Format(http.StatusForbidden, "%v", err)
+		}
+		return Error(err)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package wine_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/ctxutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoint_RequireRoles(t *testing.T) {
+	server := wine.NewTestServer(t)
+	r := server.Use(func(ctx context.Context, req *wine.Request) wine.Responder {
+		if req.Header("X-Sudo") != "" {
+			ctx = ctxutil.WithSudo(ctx)
+		}
+		if id := req.Header("X-Uid"); id != "" {
+			ctx = ctxutil.WithUser(ctx, &wine.JWTClaims{
+				UserID: 1,
+				Roles:  strings.Fields(req.Header("X-Roles")),
+				Scope:  req.Header("X-Scope"),
+			})
+		}
+		return wine.Next(ctx, req)
+	})
+	ok := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, "ok")
+	}
+	r.Get("/admin", ok).RequireRoles("admin", "root")
+	r.Get("/items", ok).RequireScopes("items:read", "items:write")
+	audits := 0
+	r.Get("/audit", func(ctx context.Context, req *wine.Request) wine.Responder {
+		audits++
+		return wine.Next(ctx, req)
+	}, ok).RequireRoles("admin")
+	custom := r.Group("custom")
+	custom.SetAuthorizer(wine.AuthorizerFunc(func(ctx context.Context, req *wine.Request, roles, scopes []string) error {
+		return nil
+	}))
+	custom.Get("/admin", ok).RequireRoles("admin")
+	url := server.Run()
+
+	get := func(t *testing.T, path string, header map[string]string) int {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusUnauthorized, get(t, "/admin", nil))
+	require.Equal(t, http.StatusForbidden, get(t, "/admin", map[string]string{"X-Uid": "1", "X-Roles": "user"}))
+	require.Equal(t, http.StatusOK, get(t, "/admin", map[string]string{"X-Uid": "1", "X-Roles": "user root"}))
+	require.Equal(t, http.StatusOK, get(t, "/admin", map[string]string{"X-Sudo": "1"}))
+
+	require.Equal(t, http.StatusForbidden, get(t, "/items", map[string]string{"X-Uid": "1", "X-Scope": "items:read"}))
+	require.Equal(t, http.StatusOK, get(t, "/items", map[string]string{"X-Uid": "1", "X-Scope": "items:write items:read"}))
+
+	// Permission is checked before endpoint's first handler
+	require.Equal(t, http.StatusForbidden, get(t, "/audit", map[string]string{"X-Uid": "1", "X-Roles": "user"}))
+	require.Equal(t, 0, audits)
+	require.Equal(t, http.StatusOK, get(t, "/audit", map[string]string{"X-Uid": "1", "X-Roles": "admin"}))
+	require.Equal(t, 1, audits)
+
+	t.Run("Authorizer", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, get(t, "/custom/admin", nil))
+		require.Equal(t, http.StatusUnauthorized, get(t, "/admin", nil))
+	})
+
+	t.Run("ListEndpoints", func(t *testing.T) {
+		resp, err := http.Get(url + "/_wine/endpoints")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(b), "roles=admin|root")
+		require.Contains(t, string(b), "scopes=items:read,items:write")
+	})
+}
@@ -2,6 +2,7 @@ package wine
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -19,6 +20,7 @@ import (
 	"github.com/gopub/wine/httpvalue"
 	iopkg "github.com/gopub/wine/internal/io"
 	"github.com/gopub/wine/urlutil"
+	"google.golang.org/protobuf/proto"
 )
 
 type HeaderBuilder interface {
@@ -31,6 +33,9 @@ type Client struct {
 	HeaderBuilder  HeaderBuilder
 	RequestLogging bool
 	Decoder        func(resp *http.Response, result interface{}) error
+	// MinRequestCompressionSize enables gzip compression of request bodies no smaller than it, zero means no compression.
+	// Make sure the server supports request decompression, e.g. wine server.
+	MinRequestCompressionSize int
 
 	getServerTime *ClientEndpoint
 }
@@ -252,7 +257,7 @@ func (c *ClientEndpoint) SetBasicAuthorization(account, password string) {
 
 func (c *ClientEndpoint) Call(ctx context.Context, input interface{}, output interface{}) error {
 	input = conv.Indirect(input)
-	var body io.Reader
+	var data []byte
 	var contentType string
 	u := *c.url
 	switch iv := input.(type) {
@@ -266,18 +271,31 @@ func (c *ClientEndpoint) Call(ctx context.Context, input interface{}, output int
 			}
 			u.RawQuery = query.Encode()
 		} else if len(iv) > 0 {
-			body = strings.NewReader(iv.Encode())
+			data = []byte(iv.Encode())
 			contentType = httpvalue.FormURLEncoded
 		}
 	case nil:
 		break
 	default:
 		contentType = httpvalue.JsonUTF8
-		data, err := json.Marshal(input)
+		var err error
+		data, err = json.Marshal(input)
 		if err != nil {
 			return fmt.Errorf("cannot marshal: %w", err)
 		}
-		body = bytes.NewBuffer(data)
+	}
+
+	var body io.Reader
+	var contentEncoding string
+	if data != nil {
+		if n := c.c.MinRequestCompressionSize; n > 0 && len(data) >= n {
+			b, err := gzipBytes(data)
+			if err != nil {
+				return fmt.Errorf("cannot compress: %w", err)
+			}
+			data, contentEncoding = b, "gzip"
+		}
+		body = bytes.NewReader(data)
 	}
 	req, err := http.NewRequestWithContext(ctx, c.method, u.String(), body)
 	if err != nil {
@@ -289,6 +307,17 @@ func (c *ClientEndpoint) Call(ctx context.Context, input interface{}, output int
 	if contentType != "" {
 		req.Header.Set(httpvalue.ContentType, contentType)
 	}
+	if contentEncoding != "" {
+		req.Header.Set(httpvalue.ContentEncoding, contentEncoding)
+	}
+	if req.Header.Get(httpvalue.Accept) == "" && c.c.header.Get(httpvalue.Accept) == "" {
+		// Accept problem details in order to decode errors
+		if _, ok := output.(proto.Message); ok {
+			req.Header.Set(httpvalue.Accept, httpvalue.Protobuf+", "+httpvalue.ProblemJSON+", */*;q=0.8")
+		} else {
+			req.Header.Set(httpvalue.Accept, httpvalue.JSON+", "+httpvalue.ProblemJSON+", */*;q=0.8")
+		}
+	}
 	reqID := uuid.NewString()
 	req.Header.Set(httpvalue.RequestID, reqID)
 	err = c.c.Do(req, output)
@@ -297,3 +326,15 @@ func (c *ClientEndpoint) Call(ctx context.Context, input interface{}, output int
 	}
 	return nil
 }
+
+func gzipBytes(data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
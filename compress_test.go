@@ -0,0 +1,199 @@
+package wine_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	offers := []string{"br", "zstd", "gzip", "deflate"}
+	cases := map[string]string{
+		"":                              "identity",
+		"gzip, deflate, br":             "br",
+		"gzip;q=1, br;q=0.5":            "gzip",
+		"*":                             "br",
+		"*;q=0.5, br;q=0":               "zstd",
+		"compress":                      "identity",
+		"compress, identity;q=0":        "",
+		"*;q=0":                         "",
+		"gzip;q=0.5, identity":          "identity",
+		"deflate;q=0.5, identity;q=0.5": "deflate",
+	}
+	for accept, encoding := range cases {
+		h := http.Header{}
+		if accept != "" {
+			h.Set("Accept-Encoding", accept)
+		}
+		require.Equal(t, encoding, httpvalue.NegotiateEncoding(h, offers), accept)
+	}
+}
+
+func TestServer_AutoCompression(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.MinCompressionSize = 100
+	long := strings.Repeat("hello wine ", 100)
+	server.Get("/text", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, long)
+	})
+	server.Get("/short", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, "hello")
+	})
+	server.Get("/stream", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.ResponderFunc(func(ctx context.Context, w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 0; i < 100; i++ {
+				io.WriteString(w, "data: hello wine\n\n")
+			}
+		})
+	})
+	server.Get("/image", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Image("image/png", make([]byte, 1024))
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, path, encoding string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", encoding)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var r io.Reader = resp.Body
+		switch resp.Header.Get("Content-Encoding") {
+		case "gzip":
+			r, err = gzip.NewReader(r)
+			require.NoError(t, err)
+		case "deflate":
+			r, err = zlib.NewReader(r)
+			require.NoError(t, err)
+		case "br":
+			r = brotli.NewReader(r)
+		case "zstd":
+			d, err := zstd.NewReader(r)
+			require.NoError(t, err)
+			defer d.Close()
+			r = d
+		}
+		body, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		return resp, string(body)
+	}
+
+	for _, encoding := range []string{"gzip", "deflate", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			resp, body := get(t, "/text", encoding)
+			require.Equal(t, encoding, resp.Header.Get("Content-Encoding"))
+			require.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+			require.Equal(t, long, body)
+		})
+	}
+
+	t.Run("Identity", func(t *testing.T) {
+		resp, body := get(t, "/text", "identity")
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+		require.Equal(t, long, body)
+	})
+
+	t.Run("Short", func(t *testing.T) {
+		resp, body := get(t, "/short", "gzip")
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.Equal(t, "hello", body)
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		resp, body := get(t, "/stream", "gzip, br;q=0.5")
+		require.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+		require.Equal(t, strings.Repeat("data: hello wine\n\n", 100), body)
+	})
+
+	t.Run("Image", func(t *testing.T) {
+		resp, body := get(t, "/image", "gzip")
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		require.Len(t, body, 1024)
+	})
+}
+
+func TestServer_RequestDecompression(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.MaxDecompressedSize = 1024
+	// Without recovery, a panic while handling rejected bodies breaks the connection
+	server.Recovery = false
+	server.Post("/items", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusOK, req.Model)
+	}).SetModel(testUser{})
+	url := server.Run()
+
+	post := func(t *testing.T, encoding string, body string) *http.Response {
+		var buf bytes.Buffer
+		switch encoding {
+		case "gzip":
+			w := gzip.NewWriter(&buf)
+			io.WriteString(w, body)
+			w.Close()
+		case "br":
+			w := brotli.NewWriter(&buf)
+			io.WriteString(w, body)
+			w.Close()
+		case "deflate":
+			w := zlib.NewWriter(&buf)
+			io.WriteString(w, body)
+			w.Close()
+		case "raw-deflate":
+			w, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+			io.WriteString(w, body)
+			w.Close()
+			encoding = "deflate"
+		default:
+			buf.WriteString(body)
+		}
+		req, err := http.NewRequest(http.MethodPost, url+"/items", &buf)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", encoding)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("Gzip", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, post(t, "gzip", `{"name":"tom"}`).StatusCode)
+	})
+	t.Run("Brotli", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, post(t, "br", `{"name":"tom"}`).StatusCode)
+	})
+	t.Run("Deflate", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, post(t, "deflate", `{"name":"tom"}`).StatusCode)
+		require.Equal(t, http.StatusOK, post(t, "raw-deflate", `{"name":"tom"}`).StatusCode)
+	})
+	t.Run("TooLarge", func(t *testing.T) {
+		body := `{"name":"` + strings.Repeat("a", 2048) + `"}`
+		require.Equal(t, http.StatusRequestEntityTooLarge, post(t, "gzip", body).StatusCode)
+	})
+	t.Run("Unsupported", func(t *testing.T) {
+		require.Equal(t, http.StatusUnsupportedMediaType, post(t, "compress", `{"name":"tom"}`).StatusCode)
+	})
+	t.Run("Client", func(t *testing.T) {
+		c := server.Client()
+		c.MinRequestCompressionSize = 10
+		var u testUser
+		err := c.Post(context.Background(), url+"/items", &testUser{Name: strings.Repeat("a", 100)}, &u)
+		require.NoError(t, err)
+		require.Equal(t, strings.Repeat("a", 100), u.Name)
+	})
+}
@@ -0,0 +1,43 @@
+package wine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gopub/wine/httpvalue"
+	"github.com/gopub/wine/internal/respond"
+	"github.com/gopub/wine/openapi"
+	"google.golang.org/protobuf/proto"
+)
+
+// checkResponseContract checks whether the JSON response matches the response model declared by endpoint
+func checkResponseContract(e *Endpoint, r Responder) error {
+	for {
+		hr, ok := r.(*headerResponder)
+		if !ok {
+			break
+		}
+		r = hr.Responder
+	}
+	resp, ok := r.(*respond.Response)
+	if !ok {
+		return nil
+	}
+	m := e.ResponseModel(resp.Status())
+	if m == nil {
+		return nil
+	}
+	if _, ok := m.(proto.Message); ok {
+		return nil
+	}
+	if !strings.Contains(resp.Header().Get(httpvalue.ContentType), httpvalue.JSON) {
+		return fmt.Errorf("expected %s instead of %s", httpvalue.JSON, resp.Header().Get(httpvalue.ContentType))
+	}
+	body, err := resp.Body()
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	c := openapi.NewComponents()
+	return c.ValidateJSON(c.SchemaOf(reflect.TypeOf(m)), body)
+}
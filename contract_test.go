@@ -0,0 +1,57 @@
+package wine_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gopub/types"
+	"github.com/gopub/wine"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ResponseContract(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.ResponseContract = wine.ContractFail
+	server.Get("/valid", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusOK, &testItem{ID: 1, Title: "a", CreatedAt: time.Now()})
+	}).SetResponseModel(http.StatusOK, testItem{})
+	server.Get("/list", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusOK, []*testItem{{ID: 1, Owner: &testUser{Name: "tom"}}})
+	}).SetResponseModel(http.StatusOK, []*testItem{})
+	server.Get("/missing", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusOK, types.M{"id": 1, "title": "a"})
+	}).SetResponseModel(http.StatusOK, testItem{})
+	server.Get("/type", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusOK, types.M{"id": "1", "title": "a", "created_at": time.Now()})
+	}).SetResponseModel(http.StatusOK, testItem{})
+	server.Get("/unexpected", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusOK, types.M{"id": 1, "title": "a", "created_at": time.Now(), "price": 1})
+	}).SetResponseModel(http.StatusOK, &testItem{})
+	server.Get("/undeclared", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.JSON(http.StatusCreated, types.M{"price": 1})
+	}).SetResponseModel(http.StatusOK, &testItem{})
+	url := server.Run()
+
+	cases := map[string]int{
+		"/valid":      http.StatusOK,
+		"/list":       http.StatusOK,
+		"/missing":    http.StatusInternalServerError,
+		"/type":       http.StatusInternalServerError,
+		"/unexpected": http.StatusInternalServerError,
+		"/undeclared": http.StatusCreated,
+	}
+	for path, status := range cases {
+		t.Run(path, func(t *testing.T) {
+			resp, err := http.Get(url + path)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, status, resp.StatusCode)
+		})
+	}
+
+	op := server.OpenAPI().Paths["/valid"].Get
+	require.NotNil(t, op)
+	require.Equal(t, "#/components/schemas/testItem", op.Responses["200"].Content["application/json"].Schema.Ref)
+}
@@ -0,0 +1,232 @@
+package wine
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopub/wine/httpvalue"
+	"github.com/gopub/wine/internal/respond"
+)
+
+// CORSPolicy defines how to respond to cross-origin requests
+type CORSPolicy struct {
+	// AllowOrigins contains exact origins(e.g. https://example.com), wildcard subdomain origins(e.g. https://*.example.com) or "*"
+	AllowOrigins []string
+	// AllowOriginRegexps matches origins with regular expressions
+	AllowOriginRegexps []*regexp.Regexp
+	// AllowOriginFunc decides whether the origin is allowed
+	AllowOriginFunc func(origin string) bool
+
+	// AllowHeaders contains request headers allowed in actual requests. "*" allows any headers
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	// MaxAge indicates how long the results of a preflight request can be cached
+	MaxAge time.Duration
+}
+
+// corsSafelistedHeaders are always allowed without being listed in Access-Control-Allow-Headers
+var corsSafelistedHeaders = map[string]bool{
+	"accept":           true,
+	"accept-language":  true,
+	"content-language": true,
+	"content-type":     true,
+}
+
+// AllowsOrigin reports whether origin is allowed by p
+func (p *CORSPolicy) AllowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, o := range p.AllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) || matchWildcardOrigin(o, origin) {
+			return true
+		}
+	}
+	for _, re := range p.AllowOriginRegexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return p.AllowOriginFunc != nil && p.AllowOriginFunc(origin)
+}
+
+func (p *CORSPolicy) allowsAnyOrigin() bool {
+	for _, o := range p.AllowOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsHeaders reports whether all request headers are allowed by p
+func (p *CORSPolicy) AllowsHeaders(headers []string) bool {
+	for _, h := range headers {
+		if corsSafelistedHeaders[strings.ToLower(h)] {
+			continue
+		}
+		found := false
+		for _, a := range p.AllowHeaders {
+			if a == "*" || strings.EqualFold(a, h) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// writeOriginHeader writes headers shared by preflight and actual requests
+func (p *CORSPolicy) writeOriginHeader(h http.Header, origin string) {
+	// "*" can't be used with credentials, and response varies on origin unless it's "*"
+	if p.allowsAnyOrigin() && !p.AllowCredentials {
+		h.Set(httpvalue.ACLAllowOrigin, "*")
+	} else {
+		h.Set(httpvalue.ACLAllowOrigin, origin)
+		httpvalue.AddVary(h, httpvalue.Origin)
+	}
+	if p.AllowCredentials {
+		h.Set(httpvalue.ACLAllowCredentials, "true")
+	} else {
+		h.Del(httpvalue.ACLAllowCredentials)
+	}
+}
+
+// matchWildcardOrigin matches origin with pattern like https://*.example.com
+func matchWildcardOrigin(pattern, origin string) bool {
+	i := strings.Index(pattern, "*.")
+	if i < 0 {
+		return false
+	}
+	prefix, suffix := strings.ToLower(pattern[:i]), strings.ToLower(pattern[i+1:])
+	origin = strings.ToLower(origin)
+	if len(origin) <= len(prefix)+len(suffix) {
+		return false
+	}
+	if !strings.HasPrefix(origin, prefix) || !strings.HasSuffix(origin, suffix) {
+		return false
+	}
+	sub := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(sub, "/:")
+}
+
+func isPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions &&
+		req.Header.Get(httpvalue.Origin) != "" &&
+		req.Header.Get(httpvalue.ACLRequestMethod) != ""
+}
+
+// isSameOrigin reports whether origin is the same with the request's host.
+// Browsers send Origin in same-origin POST requests, which should not be treated as cross-origin.
+func isSameOrigin(req *http.Request, origin string) bool {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return strings.EqualFold(origin, scheme+"://"+req.Host)
+}
+
+// preflightPolicy returns CORS policy if req is a preflight request for an endpoint with CORS policy
+func (s *Server) preflightPolicy(req *Request) *CORSPolicy {
+	if !isPreflight(req.request) {
+		return nil
+	}
+	method := strings.ToUpper(req.Header(httpvalue.ACLRequestMethod))
+	if e, _, _ := s.MatchHost(req.request.Host, method, req.NormalizedPath()); e != nil {
+		return e.CORSPolicy()
+	}
+	return s.md.CORS
+}
+
+func (s *Server) handlePreflight(_ context.Context, req *Request) Responder {
+	np := req.NormalizedPath()
+	origin := req.Header(httpvalue.Origin)
+	method := strings.ToUpper(req.Header(httpvalue.ACLRequestMethod))
+	e, _, _ := s.MatchHost(req.request.Host, method, np)
+	if e == nil || e.CORSPolicy() == nil {
+		return Text(http.StatusForbidden, "CORS: method %s is not allowed", method)
+	}
+	p := e.CORSPolicy()
+	if !p.AllowsOrigin(origin) {
+		return Text(http.StatusForbidden, "CORS: origin %s is not allowed", origin)
+	}
+
+	var headers []string
+	for _, v := range strings.Split(req.Header(httpvalue.ACLRequestHeaders), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			headers = append(headers, v)
+		}
+	}
+	if !p.AllowsHeaders(headers) {
+		return Text(http.StatusForbidden, "CORS: headers %s are not allowed", strings.Join(headers, ","))
+	}
+
+	// Only advertise methods whose endpoints share the policy
+	methods := []string{method, http.MethodOptions}
+	for _, m := range s.MatchHostScopes(req.request.Host, np) {
+		if m == "" {
+			// Bound with any method
+			continue
+		}
+		if me, _, _ := s.MatchHost(req.request.Host, m, np); me != nil && me.CORSPolicy() == p {
+			methods = append(methods, m)
+		}
+	}
+	return respond.Func(func(ctx context.Context, rw http.ResponseWriter) {
+		h := rw.Header()
+		p.writeOriginHeader(h, origin)
+		h.Set(httpvalue.ACLAllowMethods, strings.Join(uniqueStrings(methods), ","))
+		if len(headers) > 0 {
+			h.Set(httpvalue.ACLAllowHeaders, strings.Join(headers, ","))
+		}
+		if p.MaxAge > 0 {
+			h.Set(httpvalue.ACLMaxAge, strconv.Itoa(int(p.MaxAge/time.Second)))
+		}
+		httpvalue.AddVary(h, httpvalue.ACLRequestMethod)
+		httpvalue.AddVary(h, httpvalue.ACLRequestHeaders)
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// checkCORS writes CORS headers for an actual cross-origin request, and returns false if origin is not allowed
+func checkCORS(p *CORSPolicy, req *http.Request, rw http.ResponseWriter) bool {
+	if p == nil {
+		return true
+	}
+	if !p.allowsAnyOrigin() || p.AllowCredentials {
+		// Response depends on origin, so caches must not serve it to other origins
+		httpvalue.AddVary(rw.Header(), httpvalue.Origin)
+	}
+	origin := req.Header.Get(httpvalue.Origin)
+	if origin == "" || isSameOrigin(req, origin) {
+		return true
+	}
+	if !p.AllowsOrigin(origin) {
+		return false
+	}
+	p.writeOriginHeader(rw.Header(), origin)
+	if len(p.ExposeHeaders) > 0 {
+		rw.Header().Set(httpvalue.ACLExposeHeaders, strings.Join(p.ExposeHeaders, ","))
+	}
+	return true
+}
+
+func uniqueStrings(a []string) []string {
+	m := make(map[string]bool, len(a))
+	l := make([]string, 0, len(a))
+	for _, s := range a {
+		if !m[s] {
+			m[s] = true
+			l = append(l, s)
+		}
+	}
+	return l
+}
@@ -0,0 +1,144 @@
+package wine_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSPolicy_AllowsOrigin(t *testing.T) {
+	p := &wine.CORSPolicy{
+		AllowOrigins:       []string{"https://example.com", "https://*.example.org"},
+		AllowOriginRegexps: []*regexp.Regexp{regexp.MustCompile(`^http://localhost:\d+$`)},
+		AllowOriginFunc: func(origin string) bool {
+			return origin == "https://callback.com"
+		},
+	}
+	require.True(t, p.AllowsOrigin("https://example.com"))
+	require.True(t, p.AllowsOrigin("https://EXAMPLE.com"))
+	require.True(t, p.AllowsOrigin("https://api.example.org"))
+	require.True(t, p.AllowsOrigin("https://a.b.example.org"))
+	require.True(t, p.AllowsOrigin("http://localhost:8080"))
+	require.True(t, p.AllowsOrigin("https://callback.com"))
+	require.False(t, p.AllowsOrigin("https://example.org"))
+	require.False(t, p.AllowsOrigin("http://api.example.org"))
+	require.False(t, p.AllowsOrigin("https://evil.com/.example.org"))
+	require.False(t, p.AllowsOrigin("https://example.com.evil.com"))
+	require.False(t, p.AllowsOrigin(""))
+}
+
+func TestServer_CORS(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.SetCORSPolicy(&wine.CORSPolicy{
+		AllowOrigins:     []string{"https://*.example.com"},
+		AllowHeaders:     []string{"Authorization"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+	handler := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	}
+	server.Get("/items", handler)
+	server.Post("/items", handler)
+	server.Delete("/items", handler).SetCORSPolicy(&wine.CORSPolicy{AllowOrigins: []string{"https://admin.example.com"}})
+	server.Get("/public", handler).SetCORSPolicy(&wine.CORSPolicy{AllowOrigins: []string{"*"}})
+	url := server.Run()
+
+	preflight := func(path, origin, method, headers string) *http.Response {
+		req, err := http.NewRequest(http.MethodOptions, url+path, nil)
+		require.NoError(t, err)
+		req.Header.Set(httpvalue.Origin, origin)
+		req.Header.Set(httpvalue.ACLRequestMethod, method)
+		if headers != "" {
+			req.Header.Set(httpvalue.ACLRequestHeaders, headers)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("Preflight", func(t *testing.T) {
+		resp := preflight("/items", "https://app.example.com", http.MethodPost, "authorization, content-type")
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.Equal(t, "https://app.example.com", resp.Header.Get(httpvalue.ACLAllowOrigin))
+		require.Equal(t, "true", resp.Header.Get(httpvalue.ACLAllowCredentials))
+		require.Equal(t, "3600", resp.Header.Get(httpvalue.ACLMaxAge))
+		require.Contains(t, resp.Header.Get(httpvalue.ACLAllowMethods), http.MethodPost)
+		require.Contains(t, resp.Header.Get(httpvalue.ACLAllowMethods), http.MethodGet)
+		// DELETE has a different policy
+		require.NotContains(t, resp.Header.Get(httpvalue.ACLAllowMethods), http.MethodDelete)
+		require.Contains(t, resp.Header.Values(httpvalue.Vary), httpvalue.Origin)
+	})
+
+	t.Run("PreflightDisallowedOrigin", func(t *testing.T) {
+		resp := preflight("/items", "https://evil.com", http.MethodPost, "")
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+		require.Empty(t, resp.Header.Get(httpvalue.ACLAllowOrigin))
+	})
+
+	t.Run("PreflightDisallowedHeader", func(t *testing.T) {
+		resp := preflight("/items", "https://app.example.com", http.MethodPost, "X-Secret")
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("PreflightDisallowedMethod", func(t *testing.T) {
+		resp := preflight("/public", "https://app.example.com", http.MethodDelete, "")
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Actual", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url+"/items", nil)
+		require.NoError(t, err)
+		req.Header.Set(httpvalue.Origin, "https://app.example.com")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "https://app.example.com", resp.Header.Get(httpvalue.ACLAllowOrigin))
+		require.Equal(t, "X-Total", resp.Header.Get(httpvalue.ACLExposeHeaders))
+	})
+
+	t.Run("ActualDisallowedOrigin", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url+"/items", nil)
+		require.NoError(t, err)
+		req.Header.Set(httpvalue.Origin, "https://evil.com")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("EndpointPolicy", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url+"/public", nil)
+		require.NoError(t, err)
+		req.Header.Set(httpvalue.Origin, "https://evil.com")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "*", resp.Header.Get(httpvalue.ACLAllowOrigin))
+	})
+}
+
+func TestRouter_SetCORSPolicy(t *testing.T) {
+	server := wine.NewTestServer(t)
+	handler := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	}
+	before := server.Get("/before", handler)
+	p := &wine.CORSPolicy{AllowOrigins: []string{"*"}}
+	server.SetCORSPolicy(p)
+	after := server.Get("/after", handler)
+	grouped := server.Group("v1").Get("/items", handler)
+	require.Nil(t, before.CORSPolicy())
+	require.Equal(t, p, after.CORSPolicy())
+	require.Equal(t, p, grouped.CORSPolicy())
+}
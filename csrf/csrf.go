@@ -0,0 +1,246 @@
+package csrf
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gopub/environ"
+	"github.com/gopub/errors"
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/session"
+)
+
+var logger = wine.Logger()
+
+// Mode decides where the token is kept on server side
+type Mode int
+
+const (
+	// DoubleSubmit keeps the token in a cookie, and requests must submit the same token in header or form field
+	DoubleSubmit Mode = iota
+	// SessionStore keeps the token in session.Get(ctx), which requires session.NewHandler to be used before
+	SessionStore
+)
+
+func (m Mode) String() string {
+	switch m {
+	case DoubleSubmit:
+		return "double_submit"
+	case SessionStore:
+		return "session_store"
+	default:
+		return fmt.Sprintf("mode(%d)", int(m))
+	}
+}
+
+const tokenSize = 32
+
+type Options struct {
+	Mode Mode `json:"mode"`
+	// CookieName is the name of token cookie in DoubleSubmit mode
+	CookieName   string `json:"cookie_name"`
+	CookiePath   string `json:"cookie_path"`
+	CookieDomain string `json:"cookie_domain"`
+	CookieSecure bool   `json:"cookie_secure"`
+	// CookieTTL is the lifetime of token cookie, zero means the cookie expires when browser is closed
+	CookieTTL time.Duration `json:"cookie_ttl"`
+	// SessionKey is the name of token in session in SessionStore mode
+	SessionKey string `json:"session_key"`
+	// HeaderName is the request header which carries the token, e.g. set by ajax requests
+	HeaderName string `json:"header_name"`
+	// FieldName is the form field which carries the token
+	FieldName string `json:"field_name"`
+	// Exempt contains paths which are not checked. Path ends with * matches all paths with the prefix, e.g. /api/*
+	Exempt []string `json:"exempt"`
+	// ExemptFunc decides whether the request is not checked
+	ExemptFunc func(req *wine.Request) bool `json:"-"`
+}
+
+var defaultOptions *Options
+
+func DefaultOptions() *Options {
+	if defaultOptions != nil {
+		return defaultOptions
+	}
+	defaultOptions = &Options{
+		Mode:       DoubleSubmit,
+		CookieName: environ.String("wine.csrf.cookie_name", "csrftoken"),
+		CookiePath: "/",
+		SessionKey: environ.String("wine.csrf.session_key", "csrf_token"),
+		HeaderName: environ.String("wine.csrf.header_name", "X-CSRF-Token"),
+		FieldName:  environ.String("wine.csrf.field_name", "csrf_token"),
+	}
+	return defaultOptions
+}
+
+func (o *Options) isExempt(req *wine.Request) bool {
+	p := req.Request().URL.Path
+	for _, e := range o.Exempt {
+		if strings.HasSuffix(e, "*") {
+			if strings.HasPrefix(p, e[:len(e)-1]) {
+				return true
+			}
+		} else if p == e {
+			return true
+		}
+	}
+	return o.ExemptFunc != nil && o.ExemptFunc(req)
+}
+
+type contextKey int
+
+const (
+	keyToken contextKey = iota + 1
+	keyFieldName
+)
+
+// Token returns the csrf token of the request, which should be rendered in forms or returned to ajax clients
+func Token(ctx context.Context) string {
+	v, _ := ctx.Value(keyToken).(string)
+	return v
+}
+
+// TemplateField returns a hidden input which carries the csrf token of the request
+func TemplateField(ctx context.Context) template.HTML {
+	name, _ := ctx.Value(keyFieldName).(string)
+	if name == "" {
+		name = DefaultOptions().FieldName
+	}
+	return field(name, Token(ctx))
+}
+
+// FuncMap contains template function csrfField, which renders a hidden input with the token, e.g. {{csrfField .CSRFToken}}.
+// Field name is decided by DefaultOptions. Register Options.FuncMap instead if handler's field name is customized.
+var FuncMap = template.FuncMap{
+	"csrfField": func(token string) template.HTML {
+		return field(DefaultOptions().FieldName, token)
+	},
+}
+
+// FuncMap returns template function csrfField which renders a hidden input named o.FieldName.
+// It should be registered by server.AddTemplateFuncMap before adding templates
+func (o *Options) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func(token string) template.HTML {
+			return field(o.FieldName, token)
+		},
+	}
+}
+
+func field(name, token string) template.HTML {
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+		template.HTMLEscapeString(name), template.HTMLEscapeString(token)))
+}
+
+// NewHandler returns a handler which rejects unsafe requests(e.g. POST, PUT, PATCH, DELETE) with 403
+// if they don't carry the valid token in header or form field.
+func NewHandler(options *Options) wine.HandlerFunc {
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if options.Mode == DoubleSubmit && options.CookieName == "" {
+		logger.Panic("Cookie name cannot be empty")
+	}
+	if options.Mode == SessionStore && options.SessionKey == "" {
+		logger.Panic("Session key cannot be empty")
+	}
+
+	return func(ctx context.Context, req *wine.Request) wine.Responder {
+		token, isNew, err := getToken(ctx, req, options)
+		if err != nil {
+			return wine.Error(err)
+		}
+
+		if !isSafeMethod(req.Request().Method) && !options.isExempt(req) {
+			submitted := req.Header(options.HeaderName)
+			if submitted == "" {
+				submitted = req.Params().String(options.FieldName)
+			}
+			if isNew || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+				return wine.Error(errors.Forbidden("Invalid CSRF token"))
+			}
+		}
+
+		ctx = context.WithValue(ctx, keyToken, token)
+		ctx = context.WithValue(ctx, keyFieldName, options.FieldName)
+		resp := wine.Next(ctx, req)
+		if !isNew || options.Mode != DoubleSubmit {
+			return resp
+		}
+
+		cookie := &http.Cookie{
+			Name:     options.CookieName,
+			Value:    token,
+			Path:     options.CookiePath,
+			Domain:   options.CookieDomain,
+			Secure:   options.CookieSecure,
+			SameSite: http.SameSiteLaxMode,
+		}
+		if options.CookieTTL > 0 {
+			cookie.Expires = time.Now().Add(options.CookieTTL)
+		}
+		return wine.Handle(req.Request(), http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			http.SetCookie(writer, cookie)
+			resp.Respond(ctx, writer)
+		}))
+	}
+}
+
+// getToken returns the token kept on server side, or generates a new one
+func getToken(ctx context.Context, req *wine.Request, options *Options) (token string, isNew bool, err error) {
+	switch options.Mode {
+	case DoubleSubmit:
+		if c, err := req.Request().Cookie(options.CookieName); err == nil && c.Value != "" {
+			return c.Value, false, nil
+		}
+	case SessionStore:
+		ses := session.Get(ctx)
+		if ses == nil {
+			return "", false, errors.New("session is missing, session.NewHandler is required before csrf.NewHandler")
+		}
+		err = ses.Get(ctx, options.SessionKey, &token)
+		if err != nil && !errors.IsNotExist(err) {
+			return "", false, err
+		}
+		if token != "" {
+			return token, false, nil
+		}
+	default:
+		return "", false, fmt.Errorf("unsupported mode: %v", options.Mode)
+	}
+
+	token, err = newToken()
+	if err != nil {
+		return "", false, err
+	}
+	if options.Mode == SessionStore {
+		if err = session.Get(ctx).Set(ctx, options.SessionKey, token); err != nil {
+			return "", false, err
+		}
+	}
+	return token, true, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
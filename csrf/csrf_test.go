@@ -0,0 +1,213 @@
+package csrf_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/csrf"
+	"github.com/gopub/wine/session"
+	"github.com/stretchr/testify/require"
+)
+
+var tokenRegexp = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func newClient(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return &http.Client{Jar: jar}
+}
+
+func getForm(t *testing.T, c *http.Client, u string) string {
+	resp, err := c.Get(u)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	m := tokenRegexp.FindStringSubmatch(string(b))
+	require.Len(t, m, 2)
+	return m[1]
+}
+
+func post(t *testing.T, c *http.Client, u string, form url.Values, header http.Header) int {
+	req, err := http.NewRequest(http.MethodPost, u, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := c.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestNewHandler_DoubleSubmit(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.AddTemplateFuncMap(csrf.FuncMap)
+	server.AddTextTemplate("form", `<form method="post">{{csrfField .}}</form>`)
+	r := server.Use(csrf.NewHandler(&csrf.Options{
+		CookieName: "csrftoken",
+		HeaderName: "X-CSRF-Token",
+		FieldName:  "csrf_token",
+		Exempt:     []string{"/hooks/*"},
+	}))
+	r.Get("/form", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.TemplateHTML("form", csrf.Token(ctx))
+	})
+	ok := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	}
+	r.Post("/form", ok)
+	r.Post("/hooks/github", ok)
+	u := server.Run()
+
+	c := newClient(t)
+	require.Equal(t, http.StatusForbidden, post(t, c, u+"/form", nil, nil))
+	token := getForm(t, c, u+"/form")
+	require.Equal(t, token, getForm(t, c, u+"/form"))
+	require.Equal(t, http.StatusOK, post(t, c, u+"/form", url.Values{"csrf_token": {token}}, nil))
+	require.Equal(t, http.StatusOK, post(t, c, u+"/form", nil, http.Header{"X-Csrf-Token": {token}}))
+	require.Equal(t, http.StatusForbidden, post(t, c, u+"/form", url.Values{"csrf_token": {"x" + token}}, nil))
+
+	// Token of another client is not accepted
+	require.Equal(t, http.StatusForbidden, post(t, newClient(t), u+"/form", url.Values{"csrf_token": {token}}, nil))
+	require.Equal(t, http.StatusOK, post(t, newClient(t), u+"/hooks/github", nil, nil))
+}
+
+func TestNewHandler_SessionStore(t *testing.T) {
+	server := wine.NewTestServer(t)
+	r := server.Use(session.NewHandler(newProvider(), &session.Options{
+		Name:       "test",
+		TTL:        time.Minute,
+		CookiePath: "/",
+	}), csrf.NewHandler(&csrf.Options{
+		Mode:       csrf.SessionStore,
+		SessionKey: "csrf_token",
+		HeaderName: "X-CSRF-Token",
+		FieldName:  "csrf_token",
+	}))
+	r.Get("/form", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.HTML(http.StatusOK, "<form>"+string(csrf.TemplateField(ctx))+"</form>")
+	})
+	r.Post("/form", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	})
+	u := server.Run()
+
+	c := newClient(t)
+	token := getForm(t, c, u+"/form")
+	require.Equal(t, http.StatusOK, post(t, c, u+"/form", url.Values{"csrf_token": {token}}, nil))
+	require.Equal(t, http.StatusForbidden, post(t, c, u+"/form", url.Values{"csrf_token": {"x"}}, nil))
+	require.Equal(t, http.StatusForbidden, post(t, newClient(t), u+"/form", url.Values{"csrf_token": {token}}, nil))
+}
+
+func TestNewHandler_FieldName(t *testing.T) {
+	server := wine.NewTestServer(t)
+	opts := &csrf.Options{
+		CookieName: "csrftoken",
+		HeaderName: "X-CSRF-Token",
+		FieldName:  "_csrf",
+	}
+	server.AddTemplateFuncMap(opts.FuncMap())
+	server.AddTextTemplate("form", `<form method="post">{{csrfField .}}</form>`)
+	r := server.Use(csrf.NewHandler(opts))
+	r.Get("/form", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.TemplateHTML("form", csrf.Token(ctx))
+	})
+	r.Post("/form", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	})
+	u := server.Run()
+
+	c := newClient(t)
+	resp, err := c.Get(u + "/form")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	m := regexp.MustCompile(`name="_csrf" value="([^"]+)"`).FindStringSubmatch(string(b))
+	require.Len(t, m, 2)
+	require.Equal(t, http.StatusOK, post(t, c, u+"/form", url.Values{"_csrf": {m[1]}}, nil))
+}
+
+type provider struct {
+	mu       sync.Mutex
+	sessions map[string]*memSession
+}
+
+func newProvider() *provider {
+	return &provider{sessions: make(map[string]*memSession)}
+}
+
+func (p *provider) Get(ctx context.Context, id string) (session.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sessions[id]
+	if !ok {
+		return nil, errors.NotExist
+	}
+	return s, nil
+}
+
+func (p *provider) Create(ctx context.Context, id string, ttl time.Duration) (session.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := &memSession{id: id}
+	p.sessions[id] = s
+	return s, nil
+}
+
+func (p *provider) Delete(ctx context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sessions, id)
+	return nil
+}
+
+type memSession struct {
+	id   string
+	data sync.Map
+}
+
+func (s *memSession) ID() string {
+	return s.id
+}
+
+func (s *memSession) Set(ctx context.Context, name string, value interface{}) error {
+	s.data.Store(name, value)
+	return nil
+}
+
+func (s *memSession) Get(ctx context.Context, name string, ptrValue interface{}) error {
+	v, ok := s.data.Load(name)
+	if !ok {
+		return errors.NotExist
+	}
+	*ptrValue.(*string) = v.(string)
+	return nil
+}
+
+func (s *memSession) Delete(ctx context.Context, name string) error {
+	s.data.Delete(name)
+	return nil
+}
+
+func (s *memSession) Clear() error {
+	s.data = sync.Map{}
+	return nil
+}
+
+func (s *memSession) SetTTL(ttl time.Duration) error {
+	return nil
+}
@@ -24,6 +24,9 @@ const (
 	KeySudo
 	KeyRequestHeader
 	KeyBasicUser
+	KeyClaims
+	KeyErrorRenderer
+	KeyMountPrefix
 
 	keyEnd
 )
@@ -65,6 +68,16 @@ func WithTemplateManager(ctx context.Context, m *template.Manager) context.Conte
 	return context.WithValue(ctx, KeyTemplateManager, m)
 }
 
+// GetMountPrefix returns the path prefix stripped by Router.Mount, e.g. /api
+func GetMountPrefix(ctx context.Context) string {
+	v, _ := ctx.Value(KeyMountPrefix).(string)
+	return v
+}
+
+func WithMountPrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, KeyMountPrefix, prefix)
+}
+
 func Detach(ctx context.Context) context.Context {
 	newCtx := context.Background()
 	if l := log.FromContext(ctx); l != nil {
@@ -115,6 +128,15 @@ func WithUser(ctx context.Context, u User) context.Context {
 	return context.WithValue(ctx, KeyUser, u)
 }
 
+// WithClaims saves claims of authenticated token, e.g. *wine.JWTClaims
+func WithClaims(ctx context.Context, claims interface{}) context.Context {
+	return context.WithValue(ctx, KeyClaims, claims)
+}
+
+func GetClaims(ctx context.Context) interface{} {
+	return ctx.Value(KeyClaims)
+}
+
 func WithSudo(ctx context.Context) context.Context {
 	return context.WithValue(ctx, KeySudo, true)
 }
@@ -21,6 +21,6 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	s.Bind(http.MethodPost, "/upload", storage.NewFileWriter(bucket))
+	s.Bind(http.MethodPost, "/upload", storage.NewFileWriter(bucket)).SetStreamingBody(true)
 	s.Run(*pAddr)
 }
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -39,6 +40,36 @@ func (b *DiskBucket) Write(ctx context.Context, o *Object) (string, error) {
 	}
 }
 
+// WriteStream copies r into file until r is drained or ctx is done
+func (b *DiskBucket) WriteStream(ctx context.Context, name, contentType string, r io.Reader) (string, error) {
+	name = filepath.Join(b.dir, filepath.Base(name))
+	f, err := os.Create(name)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(f, &contextReader{ctx: ctx, r: r})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return "", err
+	}
+	return name, nil
+}
+
 func (b *DiskBucket) Read(ctx context.Context, name string) ([]byte, error) {
 	return ioutil.ReadFile(filepath.Join(b.dir, name))
 }
+
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -1,14 +1,18 @@
 package storage
 
 import (
+	"bufio"
 	"context"
+	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
 	"strings"
 
+	"github.com/google/uuid"
 	"github.com/gopub/errors"
 	"github.com/gopub/wine"
+	"github.com/gopub/wine/httpvalue"
 )
 
 type FileReader struct {
@@ -51,7 +55,24 @@ func NewFileWriter(w Writer) *FileWriter {
 	}
 }
 
+// HandleRequest saves uploaded files or body.
+// If the endpoint streams request body, e.g. endpoint.SetStreamingBody(true), content is copied into writer part by part,
+// and it won't be loaded into memory if writer implements StreamWriter.
 func (w *FileWriter) HandleRequest(ctx context.Context, req *wine.Request) wine.Responder {
+	if req.IsStreaming() {
+		if req.ContentType() == httpvalue.FormData {
+			mr, err := req.MultipartReader()
+			if err != nil {
+				return errors.BadRequest("read multipart: %v", err)
+			}
+			return w.saveParts(ctx, mr)
+		}
+		url, err := w.saveStream(ctx, req.Header(httpvalue.ContentType), req.BodyReader())
+		if err != nil {
+			return wine.Error(err)
+		}
+		return wine.JSON(http.StatusOK, []string{url})
+	}
 	if req.Request().MultipartForm != nil {
 		return w.saveMultipart(ctx, req.Request().MultipartForm)
 	}
@@ -99,3 +120,55 @@ func (w *FileWriter) saveMultipart(ctx context.Context, form *multipart.Form) wi
 	}
 	return wine.JSON(http.StatusOK, urls)
 }
+
+func (w *FileWriter) saveParts(ctx context.Context, mr *multipart.Reader) wine.Responder {
+	urls := make([]string, 0, 1)
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.BadRequest("read part: %v", err)
+		}
+		if p.FileName() == "" {
+			p.Close()
+			continue
+		}
+		url, err := w.saveStream(ctx, p.Header.Get(httpvalue.ContentType), p)
+		p.Close()
+		if err != nil {
+			return wine.Error(err)
+		}
+		urls = append(urls, url)
+	}
+	if len(urls) == 0 {
+		return errors.BadRequest("missing file")
+	}
+	return wine.JSON(http.StatusOK, urls)
+}
+
+func (w *FileWriter) saveStream(ctx context.Context, contentType string, r io.Reader) (string, error) {
+	sw, ok := w.w.(StreamWriter)
+	if !ok {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return "", err
+		}
+		o, err := NewObject(b)
+		if err != nil {
+			return "", err
+		}
+		return w.w.Write(ctx, o)
+	}
+
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(512)
+	if len(head) == 0 {
+		return "", errors.BadRequest("missing content")
+	}
+	if contentType == "" {
+		contentType = httpvalue.DetectContentType(head)
+	}
+	return sw.WriteStream(ctx, uuid.NewString(), contentType, br)
+}
@@ -3,6 +3,7 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"io"
 	"path/filepath"
 
 	"cloud.google.com/go/storage"
@@ -22,7 +23,10 @@ type Bucket struct {
 	baseURL      string
 }
 
-var _ winestorage.Writer = (*Bucket)(nil)
+var (
+	_ winestorage.Writer       = (*Bucket)(nil)
+	_ winestorage.StreamWriter = (*Bucket)(nil)
+)
 
 func NewBucket(name, baseURL, cacheControl string, handle *storage.BucketHandle, acl []storage.ACLRule) *Bucket {
 	return &Bucket{
@@ -52,3 +56,19 @@ func (b *Bucket) Write(ctx context.Context, obj *winestorage.Object) (string, er
 	}
 	return filepath.Join(b.baseURL, obj.Name), nil
 }
+
+func (b *Bucket) WriteStream(ctx context.Context, name, contentType string, r io.Reader) (string, error) {
+	wc := b.handle.Object(name).NewWriter(ctx)
+	wc.ACL = b.acl
+	wc.ContentType = contentType
+	wc.CacheControl = b.cacheControl
+	if _, err := io.Copy(wc, r); err != nil {
+		wc.Close()
+		return "", fmt.Errorf("copy: %w", err)
+	}
+
+	if err := wc.Close(); err != nil {
+		return "", fmt.Errorf("close: %w", err)
+	}
+	return filepath.Join(b.baseURL, name), nil
+}
@@ -4,10 +4,10 @@ go 1.15
 
 require (
 	github.com/disintegration/imaging v1.6.2
-	github.com/google/uuid v1.2.0
+	github.com/google/uuid v1.3.0
 	github.com/gopub/errors v0.1.7
-	github.com/gopub/wine v1.38.1
-	github.com/gopub/wine/httpvalue v0.1.4
+	github.com/gopub/wine v1.43.0
+	github.com/gopub/wine/httpvalue v0.1.9
 	golang.org/x/image v0.0.0-20201208152932-35266b937fa6
 )
 
@@ -1,6 +1,9 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"io"
+)
 
 type Reader interface {
 	Read(ctx context.Context, name string) ([]byte, error)
@@ -9,3 +12,8 @@ type Reader interface {
 type Writer interface {
 	Write(ctx context.Context, o *Object) (url string, err error)
 }
+
+// StreamWriter is implemented by writers which are able to save content without loading it into memory
+type StreamWriter interface {
+	WriteStream(ctx context.Context, name, contentType string, r io.Reader) (url string, err error)
+}
@@ -3,7 +3,9 @@ module github.com/gopub/wine
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/gabriel-vasile/mimetype v1.4.0 // indirect
+	github.com/golang-jwt/jwt/v4 v4.5.2
 	github.com/google/go-cmp v0.5.6
 	github.com/google/uuid v1.3.0
 	github.com/gopub/conv v0.6.1
@@ -11,18 +13,22 @@ require (
 	github.com/gopub/errors v0.1.7
 	github.com/gopub/log/v2 v2.0.1
 	github.com/gopub/types v0.3.20
-	github.com/gopub/wine/httpvalue v0.1.4
-	github.com/gopub/wine/router v0.1.6
+	github.com/gopub/wine/httpvalue v0.1.11
+	github.com/gopub/wine/router v0.1.11
 	github.com/gopub/wine/urlutil v0.1.5
 	github.com/gorilla/websocket v1.4.2
+	github.com/klauspost/compress v1.13.6
 	github.com/spf13/viper v1.9.0 // indirect
 	github.com/stretchr/testify v1.7.0
+	github.com/vmihailenco/msgpack/v5 v5.3.5
 	go.uber.org/atomic v1.9.0 // indirect
 	go.uber.org/multierr v1.7.0 // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
 	golang.org/x/net v0.0.0-20211020060615-d418f374d309 // indirect
 	golang.org/x/sys v0.0.0-20211023085530-d6a326fbbf70 // indirect
 	golang.org/x/text v0.3.7 // indirect
 	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 retract v1.42.1
@@ -11,6 +11,8 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/gopub/wine/ctxutil"
+	"github.com/gopub/wine/router"
 	"github.com/gopub/wine/urlutil"
 )
 
@@ -38,6 +40,12 @@ func (h *handlerElem) Next() *handlerElem {
 }
 
 func (h *handlerElem) HandleRequest(ctx context.Context, req *Request) Responder {
+	// Authorize once before calling endpoint's own handlers, as interceptors added by Use may authenticate the request
+	if e := req.endpoint; e != nil && e.Metadata().(*metadata).FirstHandler == (*list.Element)(h) {
+		if resp := checkPermission(ctx, req); resp != nil {
+			return resp
+		}
+	}
 	return h.Value.(Handler).HandleRequest(withNextHandler(ctx, h.Next()), req)
 }
 
@@ -72,6 +80,42 @@ func Prefix(prefix string, h Handler) Handler {
 	})
 }
 
+// stripSegments returns a handler which removes the first n segments from Path and RawPath of requests before passing to h.
+// Segments are counted after compacting slashes, and the trailing slash is kept, e.g. /api//files/ is stripped to /files/ by 1.
+// Stripped segments are appended to the mount prefix in request's context, which is prepended to redirect paths.
+func stripSegments(n int, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		prefix, p := splitSegments(req.URL.Path, n)
+		r2 := new(http.Request)
+		*r2 = *req
+		r2.URL = new(url.URL)
+		*r2.URL = *req.URL
+		r2.URL.Path = p
+		if req.URL.RawPath != "" {
+			// Escaped slash (%2F) doesn't split segments in RawPath
+			_, r2.URL.RawPath = splitSegments(req.URL.RawPath, n)
+		}
+		ctx := ctxutil.WithMountPrefix(req.Context(), ctxutil.GetMountPrefix(req.Context())+prefix)
+		h.ServeHTTP(w, r2.WithContext(ctx))
+	})
+}
+
+// splitSegments splits p into the first n segments and the rest
+func splitSegments(p string, n int) (string, string) {
+	if n == 0 {
+		return "", p
+	}
+	segments := strings.Split(router.Normalize(p), "/")
+	if n >= len(segments) {
+		return "/" + strings.Join(segments, "/"), "/"
+	}
+	s := "/" + strings.Join(segments[n:], "/")
+	if s != "/" && strings.HasSuffix(p, "/") {
+		s += "/"
+	}
+	return "/" + strings.Join(segments[:n], "/"), s
+}
+
 type prefixFS struct {
 	prefix string
 	fs     fs.FS
@@ -0,0 +1,88 @@
+package httpvalue
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// QualityValue is an element of header value with quality, e.g. application/json;q=0.9
+type QualityValue struct {
+	Value string
+	Q     float64
+}
+
+// ParseQualityValues parses header value like "text/html, application/json;q=0.9, */*;q=0.1",
+// and sorts elements by quality in descending order.
+// Elements with q=0, which means not acceptable, are kept.
+func ParseQualityValues(s string) []*QualityValue {
+	var l []*QualityValue
+	for _, elem := range strings.Split(s, ",") {
+		params := strings.Split(elem, ";")
+		v := &QualityValue{
+			Value: strings.ToLower(strings.TrimSpace(params[0])),
+			Q:     1,
+		}
+		if v.Value == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") && !strings.HasPrefix(p, "Q=") {
+				continue
+			}
+			q, err := strconv.ParseFloat(p[2:], 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			v.Q = q
+		}
+		l = append(l, v)
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		return l[i].Q > l[j].Q
+	})
+	return l
+}
+
+// matchMediaRange returns specificity of media range r matching media type t, or -1 if it doesn't match
+func matchMediaRange(r, t string) int {
+	switch {
+	case r == t:
+		return 2
+	case r == "*/*":
+		return 0
+	case strings.HasSuffix(r, "/*") && strings.HasPrefix(t, r[:len(r)-1]):
+		return 1
+	default:
+		return -1
+	}
+}
+
+// NegotiateContentType returns the best one of offered media types according to accept, which is the value of Accept header.
+// The offer with the highest quality is chosen, then the one matched more specifically, then the one offered earlier.
+// It returns the first offer if accept is empty, and empty string if none of offers is acceptable.
+func NegotiateContentType(accept string, offers []string) string {
+	if strings.TrimSpace(accept) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	ranges := ParseQualityValues(accept)
+	best, bestQ, bestSpec := "", 0.0, -1
+	for _, o := range offers {
+		t := strings.ToLower(o)
+		q, spec := 0.0, -1
+		for _, r := range ranges {
+			// Quality of the most specific range takes effect, e.g. text/html;q=0 overrides */*
+			if s := matchMediaRange(r.Value, t); s > spec {
+				q, spec = r.Q, s
+			}
+		}
+		if q > bestQ || (q == bestQ && q > 0 && spec > bestSpec) {
+			best, bestQ, bestSpec = o, q, spec
+		}
+	}
+	return best
+}
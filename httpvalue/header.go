@@ -8,20 +8,37 @@ import (
 
 const (
 	Authorization       = "Authorization"
+	Accept              = "Accept"
 	AcceptEncoding      = "Accept-Encoding"
 	ACLAllowCredentials = "Access-Control-Allow-Credentials"
 	ACLAllowHeaders     = "Access-Control-Allow-Headers"
 	ACLAllowMethods     = "Access-Control-Allow-Methods"
 	ACLAllowOrigin      = "Access-Control-Allow-Origin"
 	ACLExposeHeaders    = "Access-Control-Expose-Headers"
+	ACLMaxAge           = "Access-Control-Max-Age"
+	ACLRequestHeaders   = "Access-Control-Request-Headers"
+	ACLRequestMethod    = "Access-Control-Request-Method"
+	Origin              = "Origin"
+	Vary                = "Vary"
 	ContentType         = "Content-Type"
 	ContentDisposition  = "Content-Disposition"
 	ContentEncoding     = "Content-Encoding"
+	ContentLength       = "Content-Length"
+	ContentRange        = "Content-Range"
 	Location            = "Location"
 	Cookies             = "Cookies"
+	RetryAfter          = "Retry-After"
+	Allow               = "Allow"
+	RateLimitLimit      = "RateLimit-Limit"
+	RateLimitRemaining  = "RateLimit-Remaining"
+	RateLimitReset      = "RateLimit-Reset"
+	RateLimitPolicy     = "RateLimit-Policy"
 
 	RequestID = "X-Request-Id"
 
+	// Identity means no encoding
+	Identity = "identity"
+
 	CustomDeviceID = "X-Wine-Device-Id"
 	CustomAppID    = "X-Wine-App-Id"
 	CustomTraceID  = "X-Wine-Trace-Id"
@@ -44,17 +61,65 @@ func FileAttachment(filename string) string {
 	return fmt.Sprintf(`attachment; filename="%s"`, filename)
 }
 
+// GetAcceptEncodings returns acceptable encodings sorted by quality in descending order
 func GetAcceptEncodings(h http.Header) []string {
-	a := strings.Split(h.Get(AcceptEncoding), ",")
-	for i, s := range a {
-		a[i] = strings.TrimSpace(s)
+	var a []string
+	for _, v := range ParseQualityValues(h.Get(AcceptEncoding)) {
+		if v.Q > 0 {
+			a = append(a, v.Value)
+		}
 	}
+	return a
+}
 
-	// Remove empty strings
-	for i := len(a) - 1; i >= 0; i-- {
-		if a[i] == "" {
-			a = append(a[:i], a[i+1:]...)
+// NegotiateEncoding returns the best one of offered content codings according to Accept-Encoding header.
+// Encodings not listed get the quality of "*" if it exists.
+// It returns "identity" if none of offers is acceptable or Accept-Encoding is absent,
+// and empty string if identity is also not acceptable, e.g. "identity;q=0" or "*;q=0".
+func NegotiateEncoding(h http.Header, offers []string) string {
+	if len(h.Values(AcceptEncoding)) == 0 {
+		return Identity
+	}
+	values := ParseQualityValues(strings.Join(h.Values(AcceptEncoding), ","))
+	quality := func(encoding string) (float64, bool) {
+		q, found := 0.0, false
+		for _, v := range values {
+			if v.Value == encoding {
+				return v.Q, true
+			}
+			if v.Value == "*" {
+				q, found = v.Q, true
+			}
 		}
+		return q, found
 	}
-	return a
+
+	best, bestQ := "", 0.0
+	for _, o := range offers {
+		if q, _ := quality(strings.ToLower(o)); q > bestQ {
+			best, bestQ = o, q
+		}
+	}
+	// identity is acceptable unless it's excluded explicitly, and it's less preferred than compressions with the same quality
+	q, found := quality(Identity)
+	switch {
+	case !found && best == "":
+		return Identity
+	case found && q > bestQ:
+		return Identity
+	default:
+		return best
+	}
+}
+
+// AddVary adds value to Vary header if it doesn't exist
+func AddVary(h http.Header, value string) {
+	for _, v := range h.Values(Vary) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), value) {
+				return
+			}
+		}
+	}
+	h.Add(Vary, value)
 }
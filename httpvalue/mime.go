@@ -2,6 +2,7 @@ package httpvalue
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gabriel-vasile/mimetype"
 )
@@ -13,7 +14,9 @@ const (
 	CSS      = "text/css"
 	XML      = "application/xml"
 	XHTML    = "application/xhtml+xml"
+	YAML     = "application/yaml"
 	Protobuf = "application/x-protobuf"
+	MsgPack  = "application/msgpack"
 
 	FormData = "multipart/form-data"
 	GIF      = "image/gif"
@@ -27,6 +30,7 @@ const (
 	FormURLEncoded = "application/x-www-form-urlencoded"
 	OctetStream    = "application/octet-stream"
 	JSON           = "application/json"
+	ProblemJSON    = "application/problem+json"
 	PDF            = "application/pdf"
 	MSWord         = "application/msword"
 	GZIP           = "application/x-gzip"
@@ -48,7 +52,28 @@ const (
 
 func IsMIMETextType(typ string) bool {
 	switch typ {
-	case Plain, HTML, CSS, XML, XML2, XHTML, JSON, PlainUTF8, HtmlUTF8, JsonUTF8, XmlUTF8:
+	case Plain, HTML, CSS, XML, XML2, XHTML, JSON, ProblemJSON, PlainUTF8, HtmlUTF8, JsonUTF8, XmlUTF8:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsCompressible reports whether content of the type is worth compressing
+func IsCompressible(contentType string) bool {
+	t := strings.ToLower(contentType)
+	if i := strings.Index(t, ";"); i >= 0 {
+		t = t[:i]
+	}
+	t = strings.TrimSpace(t)
+	switch {
+	case strings.HasPrefix(t, "text/"),
+		strings.HasSuffix(t, "+json"),
+		strings.HasSuffix(t, "+xml"):
+		return true
+	}
+	switch t {
+	case JSON, XML, YAML, WASM, "application/javascript", "application/x-javascript":
 		return true
 	default:
 		return false
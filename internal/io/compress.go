@@ -1,14 +1,16 @@
 package io
 
 import (
-	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/andybalholm/brotli"
 	"github.com/gopub/wine/httpvalue"
+	"github.com/klauspost/compress/zstd"
 )
 
 var (
@@ -17,36 +19,72 @@ var (
 	_ http.Flusher  = (*CompressResponseWriter)(nil)
 )
 
-type CompressResponseWriter struct {
-	*ResponseWriter
-	compressWriter io.Writer
-	err            error
-	hasBody        bool
-}
+// Encodings are supported content codings in order of preference
+var Encodings = []string{"br", "zstd", "gzip", "deflate"}
 
-func NewCompressResponseWriter(w *ResponseWriter, encoding string) (*CompressResponseWriter, error) {
+// DefaultCompressionLevel uses the default level of each encoding
+const DefaultCompressionLevel = 0
+
+// NewEncoder creates a writer compressing data with encoding.
+// level is in range [1, 9], and it's mapped to the corresponding level of brotli and zstd.
+func NewEncoder(w io.Writer, encoding string, level int) (io.WriteCloser, error) {
+	if level < DefaultCompressionLevel || level > 9 {
+		return nil, fmt.Errorf("invalid compression level %d", level)
+	}
 	switch encoding {
 	case "gzip":
-		cw := &CompressResponseWriter{}
-		cw.ResponseWriter = w
-		cw.compressWriter = gzip.NewWriter(w)
-		w.Header().Set(httpvalue.ContentEncoding, encoding)
-		return cw, nil
+		if level == DefaultCompressionLevel {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
 	case "deflate":
-		fw, err := flate.NewWriter(w, flate.DefaultCompression)
-		if err != nil {
-			return nil, fmt.Errorf("new flate writer: %w", err)
+		// http deflate coding is zlib format rather than raw deflate
+		if level == DefaultCompressionLevel {
+			level = zlib.DefaultCompression
 		}
-		cw := &CompressResponseWriter{}
-		cw.compressWriter = fw
-		cw.ResponseWriter = w
-		w.Header().Set(httpvalue.ContentEncoding, encoding)
-		return cw, nil
+		return zlib.NewWriterLevel(w, level)
+	case "br":
+		if level == DefaultCompressionLevel {
+			// Higher levels are too slow for dynamic content
+			level = 4
+		}
+		return brotli.NewWriterLevel(w, level), nil
+	case "zstd":
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != DefaultCompressionLevel {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
 	default:
 		return nil, errors.New("unsupported encoding")
 	}
 }
 
+type CompressResponseWriter struct {
+	*ResponseWriter
+	compressWriter io.WriteCloser
+	err            error
+	hasBody        bool
+}
+
+func NewCompressResponseWriter(w *ResponseWriter, encoding string) (*CompressResponseWriter, error) {
+	return NewCompressResponseWriterLevel(w, encoding, DefaultCompressionLevel)
+}
+
+func NewCompressResponseWriterLevel(w *ResponseWriter, encoding string, level int) (*CompressResponseWriter, error) {
+	e, err := NewEncoder(w, encoding, level)
+	if err != nil {
+		return nil, fmt.Errorf("new %s writer: %w", encoding, err)
+	}
+	w.Header().Set(httpvalue.ContentEncoding, encoding)
+	w.Header().Del(httpvalue.ContentLength)
+	httpvalue.AddVary(w.Header(), httpvalue.AcceptEncoding)
+	return &CompressResponseWriter{
+		ResponseWriter: w,
+		compressWriter: e,
+	}, nil
+}
+
 func (w *CompressResponseWriter) Write(data []byte) (int, error) {
 	if !w.hasBody {
 		w.hasBody = len(data) > 0
@@ -74,9 +112,154 @@ func (w *CompressResponseWriter) Close() error {
 		w.ResponseWriter.Flush()
 		return nil
 	}
-	if closer, ok := w.compressWriter.(io.Closer); ok {
-		// Closing a writer without written data will cause an error if response status is 204 NoContent
-		return closer.Close()
+	// Closing a writer without written data will cause an error if response status is 204 NoContent
+	return w.compressWriter.Close()
+}
+
+var (
+	_ statusGetter  = (*AutoCompressResponseWriter)(nil)
+	_ http.Hijacker = (*AutoCompressResponseWriter)(nil)
+	_ http.Flusher  = (*AutoCompressResponseWriter)(nil)
+)
+
+// AutoCompressResponseWriter buffers the body until it reaches minSize, then decides whether to compress it
+// according to response's status and header. Small bodies are written without compression on Close.
+type AutoCompressResponseWriter struct {
+	*ResponseWriter
+	encoding string
+	level    int
+	minSize  int
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      *CompressResponseWriter
+}
+
+// NewAutoCompressResponseWriter creates a writer which compresses with encoding.
+// Vary: Accept-Encoding is added to compressible responses even if encoding is identity.
+func NewAutoCompressResponseWriter(w *ResponseWriter, encoding string, level, minSize int) *AutoCompressResponseWriter {
+	return &AutoCompressResponseWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		level:          level,
+		minSize:        minSize,
+	}
+}
+
+func (w *AutoCompressResponseWriter) WriteHeader(statusCode int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *AutoCompressResponseWriter) Write(data []byte) (int, error) {
+	if w.decided {
+		if w.cw != nil {
+			return w.cw.Write(data)
+		}
+		return w.ResponseWriter.Write(data)
+	}
+	w.buf = append(w.buf, data...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// Flush decides to compress compressible content immediately, e.g. streaming events
+func (w *AutoCompressResponseWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			logger.Errorf("Flush: %v", err)
+			return
+		}
+	}
+	if w.cw != nil {
+		w.cw.Flush()
+	} else {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *AutoCompressResponseWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(len(w.buf) >= w.minSize); err != nil {
+			return err
+		}
+	}
+	if w.cw != nil {
+		return w.cw.Close()
 	}
 	return nil
 }
+
+// Detach disables auto compression and returns the underlying writer, e.g. to compress with specific encoding.
+// It returns nil if compression has been decided.
+func (w *AutoCompressResponseWriter) Detach() *ResponseWriter {
+	if w.decided {
+		return nil
+	}
+	if err := w.decide(false); err != nil {
+		logger.Errorf("Detach: %v", err)
+	}
+	return w.ResponseWriter
+}
+
+// Status returns the status even if it's not written yet
+func (w *AutoCompressResponseWriter) Status() int {
+	if w.status != 0 && !w.decided {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *AutoCompressResponseWriter) decide(compress bool) error {
+	w.decided = true
+	h := w.Header()
+	if h.Get(httpvalue.ContentType) == "" && len(w.buf) > 0 {
+		h.Set(httpvalue.ContentType, httpvalue.DetectContentType(w.buf))
+	}
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	compressible := httpvalue.IsCompressible(h.Get(httpvalue.ContentType)) &&
+		h.Get(httpvalue.ContentEncoding) == "" &&
+		h.Get(httpvalue.ContentRange) == "" &&
+		status != http.StatusNoContent &&
+		status != http.StatusNotModified &&
+		status != http.StatusPartialContent
+	if compressible {
+		httpvalue.AddVary(h, httpvalue.AcceptEncoding)
+	}
+	if compressible && compress && w.encoding != "" && w.encoding != httpvalue.Identity {
+		cw, err := NewCompressResponseWriterLevel(w.ResponseWriter, w.encoding, w.level)
+		if err != nil {
+			logger.Errorf("Cannot create compress writer: %v", err)
+		} else {
+			w.cw = cw
+		}
+	}
+
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.cw != nil {
+		_, err = w.cw.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
@@ -0,0 +1,54 @@
+package io
+
+import (
+	"net"
+	"sync"
+)
+
+// ConnSet tracks hijacked connections, which are no longer managed by http.Server,
+// so that they can still be closed when server shuts down
+type ConnSet struct {
+	conns sync.Map
+}
+
+// Add starts tracking c and returns a wrapped conn which will be untracked once it's closed
+func (s *ConnSet) Add(c net.Conn) net.Conn {
+	tc := &trackedConn{
+		Conn: c,
+		set:  s,
+	}
+	s.conns.Store(tc, true)
+	return tc
+}
+
+// Len returns the number of tracked connections
+func (s *ConnSet) Len() int {
+	n := 0
+	s.conns.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// CloseAll closes all tracked connections and returns the first error
+func (s *ConnSet) CloseAll() error {
+	var err error
+	s.conns.Range(func(k, _ interface{}) bool {
+		if e := k.(*trackedConn).Close(); e != nil && err == nil {
+			err = e
+		}
+		return true
+	})
+	return err
+}
+
+type trackedConn struct {
+	net.Conn
+	set *ConnSet
+}
+
+func (c *trackedConn) Close() error {
+	c.set.conns.Delete(c)
+	return c.Conn.Close()
+}
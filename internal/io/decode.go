@@ -20,6 +20,15 @@ func DecodeResponse(resp *http.Response, result interface{}) error {
 		return fmt.Errorf("read resp body: %v", err)
 	}
 	if resp.StatusCode >= http.StatusBadRequest {
+		if httpvalue.GetContentType(resp.Header) == httpvalue.ProblemJSON {
+			p := new(Problem)
+			if err = json.Unmarshal(body, p); err == nil {
+				if p.Status == 0 {
+					p.Status = resp.StatusCode
+				}
+				return p
+			}
+		}
 		return errors.Format(resp.StatusCode, string(body))
 	}
 	if result == nil {
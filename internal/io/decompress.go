@@ -0,0 +1,146 @@
+package io
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrBodyTooLarge is returned while reading request body which exceeds the size limit
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// IsBodyTooLarge reports whether err is caused by reading request body which exceeds the size limit.
+// Errors of http.MaxBytesReader are matched by message, which may be formatted by readers like multipart.Reader
+func IsBodyTooLarge(err error) bool {
+	return errors.Is(err, ErrBodyTooLarge) || strings.Contains(err.Error(), ErrBodyTooLarge.Error())
+}
+
+// ErrUnsupportedEncoding is returned if request body is encoded with unknown content coding
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// DecompressRequest replaces request body with a reader decoding it according to Content-Encoding.
+// Reading more than maxSize decompressed bytes fails with ErrBodyTooLarge in order to defend against zip bombs.
+// maxSize <= 0 means no limit.
+func DecompressRequest(req *http.Request, maxSize int64) error {
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get(httpvalue.ContentEncoding)))
+	if encoding == "" || encoding == httpvalue.Identity || req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(req.Body)
+	case "deflate":
+		r, err = newDeflateReader(req.Body)
+	case "br":
+		r = brotli.NewReader(req.Body)
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(req.Body, zstd.WithDecoderConcurrency(1))
+		if err == nil {
+			r = d.IOReadCloser()
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+	if err != nil {
+		return fmt.Errorf("create %s reader: %w", encoding, err)
+	}
+	body := &decompressedBody{Reader: r, body: req.Body}
+	if c, ok := r.(io.Closer); ok {
+		body.decoder = c
+	}
+	if maxSize > 0 {
+		body.Reader = &limitedReader{r: r, n: maxSize}
+	}
+	req.Body = body
+	req.Header.Del(httpvalue.ContentEncoding)
+	req.Header.Del(httpvalue.ContentLength)
+	req.ContentLength = -1
+	return nil
+}
+
+// newDeflateReader reads zlib format (RFC 1950) which is required by http deflate coding.
+// Raw deflate format (RFC 1951) sent by some non-compliant clients is accepted as well
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	h, err := br.Peek(2)
+	if err == nil && h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// LimitRequestBody limits size of request body with http.MaxBytesReader.
+// Reading more than n bytes fails with ErrBodyTooLarge, and the connection will be closed after responding.
+func LimitRequestBody(w http.ResponseWriter, req *http.Request, n int64) error {
+	if req.ContentLength > n {
+		return ErrBodyTooLarge
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	req.Body = &maxBytesBody{ReadCloser: http.MaxBytesReader(w, req.Body, n), limit: n}
+	return nil
+}
+
+type maxBytesBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *maxBytesBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+type decompressedBody struct {
+	io.Reader
+	decoder io.Closer // gzip, zlib, flate or zstd reader which must be closed to release its resources
+	body    io.ReadCloser
+}
+
+func (b *decompressedBody) Close() error {
+	if b.decoder != nil {
+		b.decoder.Close()
+	}
+	return b.body.Close()
+}
+
+// limitedReader returns ErrBodyTooLarge instead of io.EOF if there are more than n bytes
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		// Check whether there is more data
+		if n, _ := l.r.Read(make([]byte, 1)); n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,46 @@
+package io
+
+import "net/http"
+
+// Problem is the problem details of an error response defined in RFC 7807
+type Problem struct {
+	Type      string `json:"type,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Code is the application error code if it's different from Status
+	Code   int           `json:"code,omitempty"`
+	Errors []*FieldError `json:"errors,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	switch {
+	case p.Detail != "":
+		return p.Detail
+	case p.Title != "":
+		return p.Title
+	default:
+		return http.StatusText(p.Status)
+	}
+}
+
+// StatusCode makes errors.GetCode return the http status
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+// FieldError describes an invalid field of request model
+type FieldError struct {
+	// Field is the json path of the field, e.g. address.city
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ":" + e.Message
+}
@@ -17,6 +17,7 @@ type RequestParams struct {
 	CookieParams types.M
 	HeaderParams types.M
 	QueryParams  types.M
+	HostParams   types.M
 	PathParams   types.M
 	BodyParams   types.M
 }
@@ -25,6 +26,7 @@ func (p *RequestParams) Combine() types.M {
 	params := types.M{}
 	params.AddMap(p.CookieParams)
 	params.AddMap(p.HeaderParams)
+	params.AddMap(p.HostParams)
 	params.AddMap(p.PathParams)
 	params.AddMap(p.QueryParams)
 	params.AddMap(p.BodyParams)
@@ -32,11 +34,7 @@ func (p *RequestParams) Combine() types.M {
 }
 
 func ReadRequest(req *http.Request, maxMemory types.ByteUnit) (*RequestParams, []byte, error) {
-	params := &RequestParams{
-		CookieParams: ReadCookies(req.Cookies()),
-		HeaderParams: ReadHeader(req.Header),
-		QueryParams:  ReadValues(req.URL.Query()),
-	}
+	params := ReadParams(req)
 	bp, body, err := ReadBody(req, maxMemory)
 	if err != nil {
 		return params, body, fmt.Errorf("read request body: %w", err)
@@ -45,6 +43,16 @@ func ReadRequest(req *http.Request, maxMemory types.ByteUnit) (*RequestParams, [
 	return params, body, nil
 }
 
+// ReadParams reads params from cookies, header and query without touching the body
+func ReadParams(req *http.Request) *RequestParams {
+	return &RequestParams{
+		CookieParams: ReadCookies(req.Cookies()),
+		HeaderParams: ReadHeader(req.Header),
+		QueryParams:  ReadValues(req.URL.Query()),
+		BodyParams:   types.M{},
+	}
+}
+
 func ReadCookies(cookies []*http.Cookie) types.M {
 	params := types.M{}
 	for _, c := range cookies {
@@ -29,6 +29,8 @@ type ResponseWriter struct {
 	http.ResponseWriter
 	status int
 	body   []byte
+
+	hijackedConns *ConnSet
 }
 
 func NewResponseWriter(rw http.ResponseWriter) *ResponseWriter {
@@ -69,10 +71,20 @@ func (w *ResponseWriter) Flush() {
 }
 
 func (w *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
-	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
-		return h.Hijack()
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err != nil || w.hijackedConns == nil {
+		return conn, rw, err
 	}
-	return nil, nil, errors.New("hijack not supported")
+	return w.hijackedConns.Add(conn), rw, nil
+}
+
+// TrackHijackedConns makes the connection be added into s if it's hijacked
+func (w *ResponseWriter) TrackHijackedConns(s *ConnSet) {
+	w.hijackedConns = s
 }
 
 func (w *ResponseWriter) Body() []byte {
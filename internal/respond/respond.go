@@ -56,7 +56,7 @@ func (r *Response) marshal() ([]byte, error) {
 	}
 	ct := r.header.Get(httpvalue.ContentType)
 	switch {
-	case strings.Contains(ct, httpvalue.JSON):
+	case strings.Contains(ct, httpvalue.JSON) || strings.Contains(ct, "+json"):
 		b, err := json.Marshal(r.value)
 		if err != nil {
 			return nil, fmt.Errorf("marshal json: %w", err)
@@ -112,8 +112,21 @@ func (r *Response) ContentLength() int {
 	return len(r.marshaledValue)
 }
 
+// Body returns the marshaled value
+func (r *Response) Body() ([]byte, error) {
+	if r.marshaledValue == nil {
+		data, err := r.marshal()
+		if err != nil {
+			return nil, err
+		}
+		r.marshaledValue = data
+	}
+	return r.marshaledValue, nil
+}
+
 func (r *Response) SetValue(v interface{}) {
 	r.value = v
+	r.marshaledValue = nil
 }
 
 type Func func(ctx context.Context, w http.ResponseWriter)
@@ -1,8 +1,10 @@
 package template
 
 import (
+	"fmt"
 	"html/template"
 	"io"
+	"path/filepath"
 )
 
 type Manager struct {
@@ -19,19 +21,30 @@ func NewManager() *Manager {
 
 // AddGlobTemplate adds a template by parsing template files with pattern
 func (m *Manager) AddGlobTemplate(pattern string) {
-	tmpl := template.Must(template.ParseGlob(pattern))
-	m.AddTemplate(tmpl)
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		panic(err)
+	}
+	if len(files) == 0 {
+		panic(fmt.Sprintf("template: pattern matches no files: %#q", pattern))
+	}
+	m.AddFilesTemplate(files...)
 }
 
 // AddFilesTemplate adds a template by parsing template files
 func (m *Manager) AddFilesTemplate(files ...string) {
-	tmpl := template.Must(template.ParseFiles(files...))
+	if len(files) == 0 {
+		panic("template: no files named in call to AddFilesTemplate")
+	}
+	// Funcs must be added before parsing
+	tmpl := template.New(filepath.Base(files[0])).Funcs(m.funcMap)
+	tmpl = template.Must(tmpl.ParseFiles(files...))
 	m.AddTemplate(tmpl)
 }
 
 // AddTextTemplate adds a template by parsing texts
 func (m *Manager) AddTextTemplate(name string, texts ...string) {
-	tmpl := template.New(name)
+	tmpl := template.New(name).Funcs(m.funcMap)
 	for _, txt := range texts {
 		tmpl = template.Must(tmpl.Parse(txt))
 	}
@@ -80,6 +93,16 @@ func (m *Manager) Execute(w io.Writer, name string, params interface{}) {
 	}
 }
 
+// ExecuteTemplate executes the template with name, and returns error if it's not found or failed
+func (m *Manager) ExecuteTemplate(w io.Writer, name string, params interface{}) error {
+	for _, tmpl := range m.templates {
+		if t := tmpl.Lookup(name); t != nil {
+			return t.Execute(w, params)
+		}
+	}
+	return fmt.Errorf("template %s is not found", name)
+}
+
 func (m *Manager) Templates() []*template.Template {
 	return m.templates
 }
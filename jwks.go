@@ -0,0 +1,266 @@
+package wine
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minJWKSRefreshInterval limits refreshing caused by unknown key ids
+const minJWKSRefreshInterval = time.Minute
+
+// JWKS is a set of keys verifying JWT, which are indexed by key id.
+// Keys are []byte for HS algorithms, *rsa.PublicKey for RS/PS algorithms and *ecdsa.PublicKey for ES algorithms.
+type JWKS struct {
+	source string
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]interface{}
+	refreshedAt time.Time
+}
+
+// NewJWKS creates an empty key set, keys can be added by AddKey
+func NewJWKS() *JWKS {
+	return &JWKS{
+		keys: make(map[string]interface{}),
+	}
+}
+
+// NewHMACJWKS creates a key set with a single secret for HS algorithms
+func NewHMACJWKS(secret []byte) *JWKS {
+	s := NewJWKS()
+	s.keys[""] = secret
+	return s
+}
+
+// LoadJWKS loads JSON Web Key Set from a file or http(s) url.
+// Keys are reloaded from the source by Refresh, or on demand if a token is signed by an unknown key.
+func LoadJWKS(ctx context.Context, source string) (*JWKS, error) {
+	s := NewJWKS()
+	s.source = source
+	s.client = http.DefaultClient
+	if err := s.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AddKey adds a key with id kid
+func (s *JWKS) AddKey(kid string, key interface{}) error {
+	switch key.(type) {
+	case []byte, *rsa.PublicKey, *ecdsa.PublicKey:
+	case *rsa.PrivateKey:
+		key = &key.(*rsa.PrivateKey).PublicKey
+	case *ecdsa.PrivateKey:
+		key = &key.(*ecdsa.PrivateKey).PublicKey
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	s.mu.Lock()
+	s.keys[kid] = key
+	s.mu.Unlock()
+	return nil
+}
+
+// Key returns the key of kid. If kid is empty and there is only one key, the key is returned
+func (s *JWKS) Key(kid string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if k, ok := s.keys[kid]; ok {
+		return k, true
+	}
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// Refresh reloads keys from the source, which replaces all existing keys in order to rotate keys
+func (s *JWKS) Refresh(ctx context.Context) error {
+	if s.source == "" {
+		return nil
+	}
+	var data []byte
+	var err error
+	if strings.HasPrefix(s.source, "http://") || strings.HasPrefix(s.source, "https://") {
+		data, err = s.fetch(ctx)
+	} else {
+		data, err = ioutil.ReadFile(s.source)
+	}
+	if err != nil {
+		return fmt.Errorf("load jwks %s: %w", s.source, err)
+	}
+	keys, err := ParseJWKS(data)
+	if err != nil {
+		return fmt.Errorf("parse jwks %s: %w", s.source, err)
+	}
+	s.mu.Lock()
+	s.keys = keys
+	s.refreshedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// AutoRefresh refreshes keys every interval until ctx is done
+func (s *JWKS) AutoRefresh(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Refresh(ctx); err != nil {
+					logger.Errorf("Refresh jwks: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// lookup returns the key of kid, and refreshes keys if kid is unknown, e.g. keys have been rotated
+func (s *JWKS) lookup(ctx context.Context, kid string) (interface{}, bool) {
+	if k, ok := s.Key(kid); ok {
+		return k, true
+	}
+	s.mu.Lock()
+	refreshable := s.source != "" && time.Since(s.refreshedAt) >= minJWKSRefreshInterval
+	if refreshable {
+		// Prevent concurrent requests from refreshing again
+		s.refreshedAt = time.Now()
+	}
+	s.mu.Unlock()
+	if !refreshable {
+		return nil, false
+	}
+	if err := s.Refresh(ctx); err != nil {
+		logger.Errorf("Refresh jwks: %v", err)
+		return nil, false
+	}
+	return s.Key(kid)
+}
+
+func (s *JWKS) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses JSON Web Key Set defined in RFC 7517. Keys for encryption are ignored
+func ParseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []*jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use == "enc" {
+			continue
+		}
+		key, err := k.decode()
+		if err != nil {
+			return nil, fmt.Errorf("decode key %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k *jwk) decode() (interface{}, error) {
+	switch k.Kty {
+	case "oct":
+		return decodeBase64URL(k.K)
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decode n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decode e: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decode x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decode y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := decodeBase64URL(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
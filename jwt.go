@@ -0,0 +1,235 @@
+package wine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/ctxutil"
+)
+
+// JWTClaims are claims of tokens issued by JWTIssuer and verified by JWTVerifier
+type JWTClaims struct {
+	jwt.RegisteredClaims
+	// UserID is the numeric user id, Subject is used if it's zero
+	UserID int64    `json:"uid,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	// Scope contains space-delimited scopes defined in RFC 8693
+	Scope string `json:"scope,omitempty"`
+	// Extra contains custom claims
+	Extra map[string]interface{} `json:"ext,omitempty"`
+}
+
+var _ ctxutil.User = (*JWTClaims)(nil)
+
+// GetID returns UserID or numeric subject, so that claims can be used as ctxutil.User
+func (c *JWTClaims) GetID() int64 {
+	if c.UserID != 0 {
+		return c.UserID
+	}
+	id, _ := strconv.ParseInt(c.Subject, 10, 64)
+	return id
+}
+
+func (c *JWTClaims) GetRoles() []string {
+	return c.Roles
+}
+
+func (c *JWTClaims) GetScopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// GetJWTClaims returns claims of the token verified by JWT auth handler
+func GetJWTClaims(ctx context.Context) *JWTClaims {
+	c, _ := ctxutil.GetClaims(ctx).(*JWTClaims)
+	return c
+}
+
+// JWTIssuer signs tokens, e.g. in login endpoints
+type JWTIssuer struct {
+	// Algorithm is the signing algorithm, e.g. HS256, RS256, ES256
+	Algorithm string
+	// Key is []byte for HS algorithms, *rsa.PrivateKey for RS/PS algorithms and *ecdsa.PrivateKey for ES algorithms
+	Key interface{}
+	// KeyID is set as kid in header, which is used to find the key in JWKS
+	KeyID    string
+	Issuer   string
+	Audience []string
+	// TTL decides expiration time, default value is one hour
+	TTL time.Duration
+}
+
+// Issue signs claims. Registered claims which are not set are filled with issuer's settings
+func (i *JWTIssuer) Issue(claims *JWTClaims) (string, error) {
+	method := jwt.GetSigningMethod(i.Algorithm)
+	if method == nil {
+		return "", fmt.Errorf("unsupported algorithm %s", i.Algorithm)
+	}
+	c := *claims
+	now := time.Now()
+	if c.Subject == "" && c.UserID != 0 {
+		c.Subject = strconv.FormatInt(c.UserID, 10)
+	}
+	if c.Issuer == "" {
+		c.Issuer = i.Issuer
+	}
+	if len(c.Audience) == 0 {
+		c.Audience = i.Audience
+	}
+	if c.ID == "" {
+		c.ID = uuid.NewString()
+	}
+	if c.IssuedAt == nil {
+		c.IssuedAt = jwt.NewNumericDate(now)
+	}
+	if c.ExpiresAt == nil {
+		ttl := i.TTL
+		if ttl <= 0 {
+			ttl = time.Hour
+		}
+		c.ExpiresAt = jwt.NewNumericDate(now.Add(ttl))
+	}
+	token := jwt.NewWithClaims(method, &c)
+	if i.KeyID != "" {
+		token.Header["kid"] = i.KeyID
+	}
+	return token.SignedString(i.Key)
+}
+
+// JWTOptions defines how to verify tokens
+type JWTOptions struct {
+	// Algorithms are accepted signing algorithms. All HS, RS, PS and ES algorithms are accepted if it's empty,
+	// as the key type in JWKS must match the algorithm anyway.
+	Algorithms []string
+	// Issuer must equal to iss claim if it's not empty
+	Issuer string
+	// Audience must contain one of aud claims if it's not empty
+	Audience []string
+	// Leeway tolerates clock skew while checking exp and nbf
+	Leeway time.Duration
+	// AllowMissingExp accepts tokens without exp claim, which never expire
+	AllowMissingExp bool
+	// Required rejects requests without token, otherwise they are passed to next handlers as anonymous requests
+	Required bool
+	// UserFunc converts claims into user, e.g. loading user from database. Claims are used as user if it's nil
+	UserFunc func(ctx context.Context, claims *JWTClaims) (ctxutil.User, error)
+}
+
+// JWTVerifier verifies signature and claims of tokens
+type JWTVerifier struct {
+	keys    *JWKS
+	options *JWTOptions
+	parser  *jwt.Parser
+}
+
+func NewJWTVerifier(keys *JWKS, options *JWTOptions) *JWTVerifier {
+	if keys == nil {
+		logger.Panic("keys is nil")
+	}
+	if options == nil {
+		options = &JWTOptions{}
+	}
+	algorithms := options.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512",
+			"PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+	}
+	return &JWTVerifier{
+		keys:    keys,
+		options: options,
+		// Claims are validated by Verify with leeway
+		parser: jwt.NewParser(jwt.WithValidMethods(algorithms), jwt.WithoutClaimsValidation()),
+	}
+}
+
+// Verify returns claims of token if it's signed by keys and its claims are valid
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*JWTClaims, error) {
+	claims := new(JWTClaims)
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := v.keys.lookup(ctx, kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown key %s", kid)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if claims.ExpiresAt == nil && !v.options.AllowMissingExp {
+		return nil, errors.New("token has no expiration time")
+	}
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Add(v.options.Leeway)) {
+		return nil, errors.New("token is expired")
+	}
+	if claims.NotBefore != nil && now.Add(v.options.Leeway).Before(claims.NotBefore.Time) {
+		return nil, errors.New("token is not valid yet")
+	}
+	if v.options.Issuer != "" && claims.Issuer != v.options.Issuer {
+		return nil, fmt.Errorf("invalid issuer %s", claims.Issuer)
+	}
+	if len(v.options.Audience) > 0 && !containsAny(claims.Audience, v.options.Audience) {
+		return nil, fmt.Errorf("invalid audience %v", claims.Audience)
+	}
+	return claims, nil
+}
+
+// NewJWTAuthHandler returns an interceptor which authenticates requests with bearer tokens.
+// User id and user are saved in context and request, and claims can be retrieved by GetJWTClaims.
+// Requests with invalid tokens are responded 401 with WWW-Authenticate header defined in RFC 6750.
+func NewJWTAuthHandler(keys *JWKS, options *JWTOptions) HandlerFunc {
+	v := NewJWTVerifier(keys, options)
+	options = v.options
+	return func(ctx context.Context, req *Request) Responder {
+		token := req.Bearer()
+		if token == "" {
+			if options.Required {
+				return WithHeader(Error(errors.Unauthorized("Missing token")),
+					http.Header{"WWW-Authenticate": {"Bearer"}})
+			}
+			return Next(ctx, req)
+		}
+
+		claims, err := v.Verify(ctx, token)
+		if err != nil {
+			return WithHeader(Error(errors.Unauthorized("Invalid token: %v", err)),
+				http.Header{"WWW-Authenticate": {`Bearer error="invalid_token"`}})
+		}
+
+		var user ctxutil.User = claims
+		if options.UserFunc != nil {
+			user, err = options.UserFunc(ctx, claims)
+			if err != nil {
+				return Error(err)
+			}
+		}
+		if user == nil || user.GetID() <= 0 {
+			return WithHeader(Error(errors.Unauthorized("Invalid token: missing user id")),
+				http.Header{"WWW-Authenticate": {`Bearer error="invalid_token"`}})
+		}
+		ctx = ctxutil.WithClaims(ctx, claims)
+		ctx = ctxutil.WithUser(ctx, user)
+		ctx = ctxutil.WithUserID(ctx, user.GetID())
+		req.SetUserID(user.GetID())
+		return Next(ctx, req)
+	}
+}
+
+func containsAny(l []string, targets []string) bool {
+	for _, s := range l {
+		for _, t := range targets {
+			if s == t {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package wine_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gopub/wine"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeJWK(kid string, key interface{}) map[string]string {
+	enc := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "n": enc(k.N), "e": enc(big.NewInt(int64(k.E)))}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": kid, "crv": k.Params().Name, "x": enc(k.X), "y": enc(k.Y)}
+	default:
+		panic("unsupported key")
+	}
+}
+
+func encodeJWKS(keys ...map[string]string) []byte {
+	b, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestNewJWTAuthHandler(t *testing.T) {
+	secret := []byte("secret")
+	issuer := &wine.JWTIssuer{Algorithm: "HS256", Key: secret, Issuer: "wine", Audience: []string{"api"}}
+	server := wine.NewTestServer(t)
+	r := server.Use(wine.NewJWTAuthHandler(wine.NewHMACJWKS(secret), &wine.JWTOptions{
+		Issuer:   "wine",
+		Audience: []string{"api"},
+	})).RequireAuth()
+	r.Get("/me", func(ctx context.Context, req *wine.Request) wine.Responder {
+		c := wine.GetJWTClaims(ctx)
+		return wine.Text(http.StatusOK, "%d %v", req.UserID(), c.GetRoles())
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, token string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, url+"/me", nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, string(b)
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		token, err := issuer.Issue(&wine.JWTClaims{UserID: 7, Roles: []string{"admin"}})
+		require.NoError(t, err)
+		resp, body := get(t, token)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "7 [admin]", body)
+	})
+
+	t.Run("Anonymous", func(t *testing.T) {
+		resp, _ := get(t, "")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("WWW-Authenticate"))
+	})
+
+	invalidCases := map[string]*wine.JWTClaims{
+		"Expired":  {UserID: 7, RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute))}},
+		"NotYet":   {UserID: 7, RegisteredClaims: jwt.RegisteredClaims{NotBefore: jwt.NewNumericDate(time.Now().Add(time.Minute))}},
+		"Issuer":   {UserID: 7, RegisteredClaims: jwt.RegisteredClaims{Issuer: "other"}},
+		"Audience": {UserID: 7, RegisteredClaims: jwt.RegisteredClaims{Audience: []string{"web"}}},
+	}
+	for name, c := range invalidCases {
+		t.Run(name, func(t *testing.T) {
+			token, err := issuer.Issue(c)
+			require.NoError(t, err)
+			resp, _ := get(t, token)
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			require.Equal(t, `Bearer error="invalid_token"`, resp.Header.Get("WWW-Authenticate"))
+		})
+	}
+
+	t.Run("MissingExp", func(t *testing.T) {
+		c := &wine.JWTClaims{UserID: 7, RegisteredClaims: jwt.RegisteredClaims{Issuer: "wine", Audience: []string{"api"}}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		require.NoError(t, err)
+		resp, _ := get(t, token)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		v := wine.NewJWTVerifier(wine.NewHMACJWKS(secret), &wine.JWTOptions{AllowMissingExp: true})
+		_, err = v.Verify(context.Background(), token)
+		require.NoError(t, err)
+	})
+
+	t.Run("Signature", func(t *testing.T) {
+		other := &wine.JWTIssuer{Algorithm: "HS256", Key: []byte("other"), Issuer: "wine", Audience: []string{"api"}}
+		token, err := other.Issue(&wine.JWTClaims{UserID: 7})
+		require.NoError(t, err)
+		resp, _ := get(t, token)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+}
+
+func TestJWTVerifier_JWKS(t *testing.T) {
+	ctx := context.Background()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("File", func(t *testing.T) {
+		name := filepath.Join(t.TempDir(), "jwks.json")
+		require.NoError(t, ioutil.WriteFile(name, encodeJWKS(encodeJWK("rsa1", &rsaKey.PublicKey)), 0600))
+		keys, err := wine.LoadJWKS(ctx, name)
+		require.NoError(t, err)
+		v := wine.NewJWTVerifier(keys, nil)
+
+		token, err := (&wine.JWTIssuer{Algorithm: "RS256", Key: rsaKey, KeyID: "rsa1"}).Issue(&wine.JWTClaims{UserID: 1})
+		require.NoError(t, err)
+		c, err := v.Verify(ctx, token)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), c.GetID())
+
+		// Rotate keys
+		ecToken, err := (&wine.JWTIssuer{Algorithm: "ES256", Key: ecKey, KeyID: "ec1"}).Issue(&wine.JWTClaims{UserID: 2})
+		require.NoError(t, err)
+		_, err = v.Verify(ctx, ecToken)
+		require.Error(t, err)
+		require.NoError(t, ioutil.WriteFile(name, encodeJWKS(encodeJWK("ec1", &ecKey.PublicKey)), 0600))
+		require.NoError(t, keys.Refresh(ctx))
+		c, err = v.Verify(ctx, ecToken)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), c.GetID())
+		_, err = v.Verify(ctx, token)
+		require.Error(t, err)
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(encodeJWKS(encodeJWK("rsa1", &rsaKey.PublicKey), encodeJWK("ec1", &ecKey.PublicKey)))
+		}))
+		defer ts.Close()
+		keys, err := wine.LoadJWKS(ctx, ts.URL)
+		require.NoError(t, err)
+		v := wine.NewJWTVerifier(keys, &wine.JWTOptions{Algorithms: []string{"ES256"}})
+
+		token, err := (&wine.JWTIssuer{Algorithm: "ES256", Key: ecKey, KeyID: "ec1"}).Issue(&wine.JWTClaims{UserID: 3})
+		require.NoError(t, err)
+		_, err = v.Verify(ctx, token)
+		require.NoError(t, err)
+
+		// Algorithm is not accepted
+		token, err = (&wine.JWTIssuer{Algorithm: "RS256", Key: rsaKey, KeyID: "rsa1"}).Issue(&wine.JWTClaims{UserID: 3})
+		require.NoError(t, err)
+		_, err = v.Verify(ctx, token)
+		require.Error(t, err)
+	})
+}
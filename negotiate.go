@@ -0,0 +1,183 @@
+package wine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gopub/wine/ctxutil"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// Encoder encodes values into response body for content negotiation
+type Encoder interface {
+	// CanEncode reports whether v can be encoded, e.g. protobuf encoder only accepts proto.Message
+	CanEncode(v interface{}) bool
+	Encode(w io.Writer, v interface{}) error
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) CanEncode(v interface{}) bool {
+	return true
+}
+
+func (jsonEncoder) Encode(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+type protobufEncoder struct{}
+
+func (protobufEncoder) CanEncode(v interface{}) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+func (protobufEncoder) Encode(w io.Writer, v interface{}) error {
+	b, err := proto.Marshal(v.(proto.Message))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+type xmlEncoder struct{}
+
+// CanEncode returns false for maps which are not supported by encoding/xml
+func (xmlEncoder) CanEncode(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t != nil && t.Kind() != reflect.Map
+}
+
+func (xmlEncoder) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) CanEncode(v interface{}) bool {
+	return true
+}
+
+func (msgpackEncoder) Encode(w io.Writer, v interface{}) error {
+	e := msgpack.NewEncoder(w)
+	e.SetCustomStructTag("json")
+	return e.Encode(v)
+}
+
+type encoderEntry struct {
+	contentType string
+	encoder     Encoder
+}
+
+// defaultEncoders are in order of server's preference
+func defaultEncoders() []*encoderEntry {
+	return []*encoderEntry{
+		{httpvalue.JSON, jsonEncoder{}},
+		{httpvalue.Protobuf, protobufEncoder{}},
+		{httpvalue.XML, xmlEncoder{}},
+		{httpvalue.MsgPack, msgpackEncoder{}},
+	}
+}
+
+// RegisterEncoder registers encoder for content type used by Negotiate.
+// Encoder of existing content type is replaced, otherwise new encoder has lower priority than registered ones.
+func (s *Server) RegisterEncoder(contentType string, e Encoder) {
+	for _, entry := range s.encoders {
+		if strings.EqualFold(entry.contentType, contentType) {
+			entry.encoder = e
+			return
+		}
+	}
+	s.encoders = append(s.encoders, &encoderEntry{contentType: contentType, encoder: e})
+}
+
+// Negotiation is a responder which encodes value in the content type accepted by client
+type Negotiation struct {
+	status   int
+	value    interface{}
+	template string
+	encoders []*encoderEntry
+}
+
+// Negotiate creates a responder which picks the content type according to request's Accept header.
+// JSON, protobuf(only for proto.Message), XML and MessagePack are supported by default, and more encoders can be registered by Server.RegisterEncoder.
+// It responds 406 if none of them is acceptable.
+func Negotiate(status int, value interface{}) *Negotiation {
+	return &Negotiation{
+		status: status,
+		value:  value,
+	}
+}
+
+// SetTemplate renders value with the template if text/html is preferred
+func (n *Negotiation) SetTemplate(name string) *Negotiation {
+	n.template = name
+	return n
+}
+
+func (n *Negotiation) Respond(ctx context.Context, w http.ResponseWriter) {
+	encoders := n.encoders
+	if encoders == nil {
+		encoders = defaultEncoders()
+	}
+	var offers []string
+	for _, e := range encoders {
+		if e.encoder.CanEncode(n.value) {
+			offers = append(offers, e.contentType)
+		}
+	}
+	if n.template != "" && ctxutil.GetTemplateManager(ctx) != nil {
+		offers = append(offers, httpvalue.HTML)
+	}
+
+	httpvalue.AddVary(w.Header(), httpvalue.Accept)
+	ct := httpvalue.NegotiateContentType(ctxutil.GetRequestHeader(ctx).Get(httpvalue.Accept), offers)
+	if ct == "" {
+		Text(http.StatusNotAcceptable, "Supported content types: %s", strings.Join(offers, ", ")).Respond(ctx, w)
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	if ct == httpvalue.HTML {
+		if err := ctxutil.GetTemplateManager(ctx).ExecuteTemplate(buf, n.template, n.value); err != nil {
+			Error(fmt.Errorf("execute template %s: %w", n.template, err)).Respond(ctx, w)
+			return
+		}
+	} else {
+		for _, e := range encoders {
+			if e.contentType == ct {
+				if err := e.encoder.Encode(buf, n.value); err != nil {
+					Error(fmt.Errorf("encode %s: %w", ct, err)).Respond(ctx, w)
+					return
+				}
+				break
+			}
+		}
+	}
+	if httpvalue.IsMIMETextType(ct) {
+		ct += "; " + httpvalue.CharsetUTF8
+	}
+	w.Header().Set(httpvalue.ContentType, ct)
+	w.WriteHeader(n.status)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		logger.Errorf("Write: %v", err)
+	}
+}
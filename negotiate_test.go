@@ -0,0 +1,101 @@
+package wine_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/gopub/types"
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type csvEncoder struct{}
+
+func (csvEncoder) CanEncode(v interface{}) bool {
+	_, ok := v.(*testUser)
+	return ok
+}
+
+func (csvEncoder) Encode(w io.Writer, v interface{}) error {
+	_, err := io.WriteString(w, "name\n"+v.(*testUser).Name)
+	return err
+}
+
+func TestNegotiate(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.AddTextTemplate("user", "<p>{{.Name}}</p>")
+	server.RegisterEncoder("text/csv", csvEncoder{})
+	server.Get("/user", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Negotiate(http.StatusOK, &testUser{Name: "tom"}).SetTemplate("user")
+	})
+	server.Get("/map", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Negotiate(http.StatusOK, types.M{"name": "tom"})
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, path, accept string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	cases := []struct {
+		path        string
+		accept      string
+		contentType string
+		body        string
+	}{
+		{"/user", "", "application/json; charset=utf-8", `{"name":"tom"}`},
+		{"/user", "application/xml", "application/xml; charset=utf-8", "<testUser><Name>tom</Name></testUser>"},
+		{"/user", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8", "<p>tom</p>"},
+		{"/user", "text/*;q=0.5, application/json;q=0.4", "text/csv", "name\ntom"},
+		{"/user", "*/*;q=0.8, application/json;q=0", "application/xml; charset=utf-8", "<testUser><Name>tom</Name></testUser>"},
+		{"/map", "application/xml, application/json;q=0.5", "application/json; charset=utf-8", `{"name":"tom"}`},
+	}
+	for _, c := range cases {
+		t.Run(c.accept, func(t *testing.T) {
+			resp, body := get(t, c.path, c.accept)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, c.contentType, resp.Header.Get("Content-Type"))
+			require.Equal(t, c.body, string(body))
+			require.Equal(t, "Accept", resp.Header.Get("Vary"))
+		})
+	}
+
+	t.Run("MessagePack", func(t *testing.T) {
+		resp, body := get(t, "/user", httpvalue.MsgPack)
+		require.Equal(t, httpvalue.MsgPack, resp.Header.Get("Content-Type"))
+		var u testUser
+		d := msgpack.NewDecoder(bytes.NewReader(body))
+		d.SetCustomStructTag("json")
+		require.NoError(t, d.Decode(&u))
+		require.Equal(t, "tom", u.Name)
+	})
+
+	t.Run("NotAcceptable", func(t *testing.T) {
+		resp, _ := get(t, "/map", "text/html")
+		require.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+	})
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{httpvalue.JSON, httpvalue.XML}
+	require.Equal(t, httpvalue.JSON, httpvalue.NegotiateContentType("", offers))
+	require.Equal(t, httpvalue.XML, httpvalue.NegotiateContentType("application/json;q=0.5, application/xml", offers))
+	require.Equal(t, httpvalue.XML, httpvalue.NegotiateContentType("application/*, application/json;q=0", offers))
+	require.Equal(t, "", httpvalue.NegotiateContentType("text/html", offers))
+}
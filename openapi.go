@@ -0,0 +1,296 @@
+package wine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gopub/environ"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/gopub/wine/openapi"
+	"github.com/gopub/wine/router"
+	"google.golang.org/protobuf/proto"
+	"gopkg.in/yaml.v2"
+)
+
+const openAPIPath = "_wine/openapi"
+
+// anyMethods are methods listed in OpenAPI document for endpoints bound with any method
+var anyMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// SetAPIInfo sets info of OpenAPI document
+func (r *Router) SetAPIInfo(info *openapi.Info) {
+	r.apiInfo = info
+}
+
+func (r *Router) APIInfo() *openapi.Info {
+	if r.apiInfo != nil {
+		return r.apiInfo
+	}
+	return &openapi.Info{
+		Title:   environ.String("wine.openapi.title", "Wine"),
+		Version: environ.String("wine.openapi.version", "1.0.0"),
+	}
+}
+
+// OpenAPI generates OpenAPI 3 document from endpoints and their models
+func (r *Router) OpenAPI() *openapi.Document {
+	doc := openapi.NewDocument(r.APIInfo())
+	for _, e := range r.ListRoutes() {
+		if reservedPaths[e.Path()] {
+			continue
+		}
+		path, params := toOpenAPIPath(e.Path())
+		methods := []string{e.Scope}
+		if e.Scope == "" {
+			methods = anyMethods
+		}
+		for _, m := range methods {
+			doc.PathItem(path).SetOperation(m, newOperation(doc.Components, m, e, params))
+		}
+	}
+	return doc
+}
+
+func (r *Router) handleOpenAPI(_ context.Context, req *Request) Responder {
+	doc := r.OpenAPI()
+	scheme := "http"
+	if req.request.TLS != nil {
+		scheme = "https"
+	}
+	doc.Servers = []*openapi.Server{{URL: scheme + "://" + req.request.Host}}
+	if req.Params().String("format") != "yaml" && !strings.Contains(req.Header("Accept"), "yaml") {
+		return JSON(http.StatusOK, doc)
+	}
+
+	// Convert via json to keep field names and order
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return Error(err)
+	}
+	var m yaml.MapSlice
+	if err = yaml.Unmarshal(b, &m); err != nil {
+		return Error(err)
+	}
+	if b, err = yaml.Marshal(m); err != nil {
+		return Error(err)
+	}
+	return ResponderFunc(func(ctx context.Context, w http.ResponseWriter) {
+		w.Header().Set(httpvalue.ContentType, httpvalue.YAML+"; "+httpvalue.CharsetUTF8)
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(b); err != nil {
+			logger.Errorf("Write: %v", err)
+		}
+	})
+}
+
+// toOpenAPIPath converts router path into OpenAPI path and returns names of path params
+// Wildcard segment is converted into a path param, e.g. /files/*name is converted into /files/{name}.
+// Constraints are removed from param segments, e.g. /items/{id:int} is converted into /items/{id}
+func toOpenAPIPath(p string) (string, []string) {
+	segments := strings.Split(p, "/")
+	var params []string
+	for i, s := range segments {
+		switch {
+		case router.IsParam(s):
+			name, _ := router.ParseParam(s)
+			segments[i] = "{" + name + "}"
+			params = append(params, name)
+		case strings.HasPrefix(s, "*"):
+			name := s[1:]
+			if name == "" {
+				name = "path"
+			}
+			segments[i] = "{" + name + "}"
+			params = append(params, name)
+		}
+	}
+	return "/" + strings.Join(segments, "/"), params
+}
+
+func newOperation(c *openapi.Components, method string, e *router.Endpoint, pathParams []string) *openapi.Operation {
+	op := &openapi.Operation{
+		OperationID: operationID(method, e.Path()),
+		Summary:     e.Description(),
+		Responses: map[string]*openapi.Response{
+			"200": {Description: http.StatusText(http.StatusOK)},
+		},
+	}
+	if tag := strings.Split(e.Path(), "/")[0]; router.IsStatic(tag) {
+		op.Tags = []string{tag}
+	}
+
+	var modelType reflect.Type
+	fields := map[string]*openapi.Field{}
+	if m := e.Model(); m != nil {
+		modelType = reflect.TypeOf(m)
+		for _, f := range openapi.Fields(modelType) {
+			fields[f.Name] = f
+		}
+	}
+
+	constraints := map[string]string{}
+	for _, s := range strings.Split(e.Path(), "/") {
+		if name, c := router.ParseParam(s); c != "" {
+			constraints[name] = c
+		}
+	}
+
+	for _, name := range pathParams {
+		p := &openapi.Parameter{
+			Name:     name,
+			In:       openapi.InPath,
+			Required: true,
+			Schema:   constraintSchema(constraints[name]),
+		}
+		if f := fields[name]; f != nil {
+			p.Schema = c.SchemaOf(f.Type)
+		} else if modelType != nil && len(pathParams) == 1 && len(fields) == 0 && !isStructType(modelType) {
+			// Model is a single value assigned from path param
+			p.Schema = c.SchemaOf(modelType)
+		}
+		op.Parameters = append(op.Parameters, p)
+	}
+
+	for status, m := range e.ResponseModels() {
+		op.Responses[strconv.Itoa(status)] = newResponse(c, status, m)
+	}
+
+	if modelType == nil {
+		return op
+	}
+
+	_, ok := op.Responses["400"]
+	if !ok && (modelType.Implements(validatorType) || reflect.PtrTo(modelType).Implements(validatorType)) {
+		op.Responses["400"] = &openapi.Response{Description: http.StatusText(http.StatusBadRequest)}
+	}
+
+	switch {
+	case modelType.Implements(reflect.TypeOf((*proto.Message)(nil)).Elem()):
+		op.RequestBody = &openapi.RequestBody{
+			Required: true,
+			Content: map[string]*openapi.MediaType{
+				httpvalue.Protobuf: {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+			},
+		}
+	case !isStructType(modelType):
+		if len(pathParams) == 0 && hasBody(method) {
+			op.RequestBody = jsonRequestBody(c.SchemaOf(modelType))
+		}
+	case hasBody(method):
+		op.RequestBody = jsonRequestBody(c.SchemaOf(modelType))
+	default:
+		for _, f := range openapi.Fields(modelType) {
+			if containsString(pathParams, f.Name) {
+				continue
+			}
+			op.Parameters = append(op.Parameters, &openapi.Parameter{
+				Name:   f.Name,
+				In:     openapi.InQuery,
+				Schema: c.SchemaOf(f.Type),
+			})
+		}
+	}
+	return op
+}
+
+func newResponse(c *openapi.Components, status int, m interface{}) *openapi.Response {
+	resp := &openapi.Response{
+		Description: http.StatusText(status),
+	}
+	if m == nil {
+		return resp
+	}
+	if _, ok := m.(proto.Message); ok {
+		resp.Content = map[string]*openapi.MediaType{
+			httpvalue.Protobuf: {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+		}
+	} else {
+		resp.Content = map[string]*openapi.MediaType{
+			httpvalue.JSON: {Schema: c.SchemaOf(reflect.TypeOf(m))},
+		}
+	}
+	return resp
+}
+
+func jsonRequestBody(s *openapi.Schema) *openapi.RequestBody {
+	return &openapi.RequestBody{
+		Required: true,
+		Content: map[string]*openapi.MediaType{
+			httpvalue.JSON:           {Schema: s},
+			httpvalue.FormURLEncoded: {Schema: s},
+		},
+	}
+}
+
+// constraintSchema returns schema of path param with constraint, e.g. {id:int} is integer
+func constraintSchema(c string) *openapi.Schema {
+	switch c {
+	case "":
+		return &openapi.Schema{Type: "string"}
+	case "int":
+		return &openapi.Schema{Type: "integer", Format: "int64"}
+	case "float":
+		return &openapi.Schema{Type: "number", Format: "double"}
+	case "bool":
+		return &openapi.Schema{Type: "boolean"}
+	case "uuid":
+		return &openapi.Schema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &openapi.Schema{Type: "string", Pattern: "^[a-zA-Z]+$"}
+	case "alnum":
+		return &openapi.Schema{Type: "string", Pattern: "^[a-zA-Z0-9]+$"}
+	default:
+		return &openapi.Schema{Type: "string", Pattern: "^(?:" + c + ")$"}
+	}
+}
+
+// operationID generates id like getItemsById for GET items/{id}
+func operationID(method, path string) string {
+	b := new(strings.Builder)
+	b.WriteString(strings.ToLower(method))
+	for _, s := range strings.Split(path, "/") {
+		if router.IsParam(s) {
+			b.WriteString("By")
+			s, _ = router.ParseParam(s)
+		} else if strings.HasPrefix(s, "*") {
+			s = s[1:]
+		}
+		for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+			return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+		}) {
+			b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+		}
+	}
+	return b.String()
+}
+
+func hasBody(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions, http.MethodTrace:
+		return false
+	default:
+		return true
+	}
+}
+
+func isStructType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+func containsString(l []string, s string) bool {
+	for _, v := range l {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
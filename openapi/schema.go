@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	bytesType         = reflect.TypeOf([]byte(nil))
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaOf returns the schema of t.
+// Named struct types are registered in c.Schemas and referenced by $ref.
+func (c *Components) SchemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		nullable = true
+	}
+	s := c.schemaOf(t)
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+func (c *Components) schemaOf(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case durationType:
+		return &Schema{Type: "integer", Format: "int64", Description: "nanoseconds"}
+	case bytesType:
+		return &Schema{Type: "string", Format: "byte"}
+	case rawMessageType:
+		return &Schema{}
+	case jsonNumberType:
+		return &Schema{Type: "number"}
+	}
+
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		// Unknown format
+		return &Schema{}
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64", Minimum: new(float64)}
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32", Minimum: new(float64)}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: c.SchemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: c.SchemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return c.structSchema(t)
+		}
+		name := c.schemaName(t)
+		if _, ok := c.Schemas[name]; !ok {
+			// Placeholder to stop recursion of self-referencing types
+			c.Schemas[name] = &Schema{}
+			c.Schemas[name] = c.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// schemaName returns type name, which is prefixed with package name if there is a conflict
+func (c *Components) schemaName(t reflect.Type) string {
+	name := t.Name()
+	if i := strings.Index(name, "["); i > 0 {
+		// Generic type
+		name = name[:i]
+	}
+	if _, ok := c.Schemas[name]; !ok || c.typeNames[name] == t {
+		c.registerType(name, t)
+		return name
+	}
+	pkg := t.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	name = strings.Title(pkg) + name
+	c.registerType(name, t)
+	return name
+}
+
+func (c *Components) registerType(name string, t reflect.Type) {
+	if c.typeNames == nil {
+		c.typeNames = map[string]reflect.Type{}
+	}
+	c.typeNames[name] = t
+}
+
+func (c *Components) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{},
+	}
+	for _, f := range Fields(t) {
+		s.Properties[f.Name] = c.SchemaOf(f.Type)
+		if f.Required {
+			s.Required = append(s.Required, f.Name)
+		}
+	}
+	return s
+}
+
+// Field is a struct field which can be encoded into json
+type Field struct {
+	Name string
+	Type reflect.Type
+	// Required is true if json tag doesn't contain omitempty, as the field is always encoded
+	Required bool
+}
+
+// Fields returns json fields of struct type t, including fields of embedded structs
+func Fields(t reflect.Type) []*Field {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var l []*Field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				l = append(l, Fields(ft)...)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		l = append(l, &Field{
+			Name:     name,
+			Type:     f.Type,
+			Required: !strings.Contains(opts, "omitempty"),
+		})
+	}
+	return l
+}
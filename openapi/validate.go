@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValidateJSON checks whether data matches schema s.
+// Null is accepted for arrays, objects and nullable schemas, as nil slices, maps and pointers are encoded into null.
+func (c *Components) ValidateJSON(s *Schema, data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return c.validate("$", s, v)
+}
+
+func (c *Components) validate(path string, s *Schema, v interface{}) error {
+	if s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		rs := c.Schemas[name]
+		if rs == nil {
+			return fmt.Errorf("%s: undefined schema %s", path, s.Ref)
+		}
+		if v == nil {
+			return nil
+		}
+		return c.validate(path, rs, v)
+	}
+
+	if v == nil {
+		switch {
+		case s.Type == "", s.Type == "array", s.Type == "object", s.Nullable:
+			return nil
+		default:
+			return fmt.Errorf("%s: expected %s, got null", path, s.Type)
+		}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok || strings.ContainsAny(n.String(), ".eE") {
+			return typeError(path, s.Type, v)
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return typeError(path, s.Type, v)
+		}
+	case "array":
+		a, ok := v.([]interface{})
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range a {
+			if err := c.validate(fmt.Sprintf("%s[%d]", path, i), s.Items, item); err != nil {
+				return err
+			}
+		}
+	case "object":
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return typeError(path, s.Type, v)
+		}
+		for _, name := range s.Required {
+			if _, ok := m[name]; !ok {
+				return fmt.Errorf("%s: missing property %s", path, name)
+			}
+		}
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ps := s.Properties[k]
+			if ps == nil {
+				ps = s.AdditionalProperties
+			}
+			if ps == nil {
+				return fmt.Errorf("%s: unexpected property %s", path, k)
+			}
+			if err := c.validate(path+"."+k, ps, m[k]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func typeError(path, expected string, v interface{}) error {
+	var got string
+	switch v.(type) {
+	case bool:
+		got = "boolean"
+	case string:
+		got = "string"
+	case json.Number:
+		got = "number"
+	case []interface{}:
+		got = "array"
+	case map[string]interface{}:
+		got = "object"
+	default:
+		got = fmt.Sprintf("%T", v)
+	}
+	return fmt.Errorf("%s: expected %s, got %s", path, expected, got)
+}
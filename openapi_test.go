@@ -0,0 +1,91 @@
+package wine_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/httpvalue"
+	"github.com/gopub/wine/openapi"
+	"github.com/stretchr/testify/require"
+)
+
+type testItem struct {
+	ID        int64     `json:"id"`
+	Title     string    `json:"title"`
+	Tags      []string  `json:"tags,omitempty"`
+	Owner     *testUser `json:"owner,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type testUser struct {
+	Name   string    `json:"name"`
+	Friend *testUser `json:"friend,omitempty"`
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	server := wine.NewTestServer(t)
+	handler := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	}
+	server.SetAPIInfo(&openapi.Info{Title: "Test", Version: "1.0"})
+	server.Get("items/{id}", handler).SetModel(int64(0)).SetDescription("Get item")
+	server.Post("items", handler).SetModel(&testItem{})
+	server.Get("items", handler).SetModel(testItem{})
+	server.Get("files/*name", handler)
+
+	doc := server.OpenAPI()
+	require.Equal(t, openapi.Version, doc.OpenAPI)
+	require.Equal(t, "Test", doc.Info.Title)
+	require.NotContains(t, doc.Paths, "/_wine/endpoints")
+
+	op := doc.Paths["/items/{id}"].Get
+	require.NotNil(t, op)
+	require.Equal(t, "getItemsById", op.OperationID)
+	require.Equal(t, "Get item", op.Summary)
+	require.Len(t, op.Parameters, 1)
+	require.Equal(t, openapi.InPath, op.Parameters[0].In)
+	require.Equal(t, "integer", op.Parameters[0].Schema.Type)
+
+	op = doc.Paths["/items"].Post
+	require.NotNil(t, op)
+	require.NotNil(t, op.RequestBody)
+	require.Equal(t, "#/components/schemas/testItem", op.RequestBody.Content[httpvalue.JSON].Schema.Ref)
+	item := doc.Components.Schemas["testItem"]
+	require.NotNil(t, item)
+	require.Equal(t, []string{"id", "title", "created_at"}, item.Required)
+	require.Equal(t, "date-time", item.Properties["created_at"].Format)
+	require.Equal(t, "#/components/schemas/testUser", item.Properties["owner"].Ref)
+	require.Equal(t, "#/components/schemas/testUser", doc.Components.Schemas["testUser"].Properties["friend"].Ref)
+
+	op = doc.Paths["/items"].Get
+	require.NotNil(t, op)
+	require.Nil(t, op.RequestBody)
+	require.Len(t, op.Parameters, 5)
+
+	op = doc.Paths["/files/{name}"].Get
+	require.NotNil(t, op)
+	require.Equal(t, "name", op.Parameters[0].Name)
+
+	url := server.Run()
+	t.Run("JSON", func(t *testing.T) {
+		var res openapi.Document
+		err := wine.DefaultClient.Get(context.Background(), url+"/_wine/openapi", nil, &res)
+		require.NoError(t, err)
+		require.Contains(t, res.Paths, "/items/{id}")
+		require.Len(t, res.Servers, 1)
+	})
+	t.Run("YAML", func(t *testing.T) {
+		resp, err := http.Get(url + "/_wine/openapi?format=yaml")
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, resp.Body.Close())
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(resp.Header.Get(httpvalue.ContentType), httpvalue.YAML))
+		require.True(t, strings.HasPrefix(string(body), "openapi: 3.0.3\n"))
+	})
+}
@@ -0,0 +1,213 @@
+package wine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopub/errors"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Argon2id parameters recommended by RFC 9106
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// HashPassword hashes password with bcrypt
+func HashPassword(password string) (string, error) {
+	b, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// HashPasswordArgon2 hashes password with argon2id, and encodes it in PHC string format,
+// e.g. $argon2id$v=19$m=65536,t=3,p=4$salt$hash
+func HashPasswordArgon2(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// VerifyPassword reports whether password matches hash generated by bcrypt or argon2id.
+// Hashes are compared in constant time.
+func VerifyPassword(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return verifyArgon2(hash, password)
+	default:
+		return false, errors.New("unsupported password hash")
+	}
+}
+
+func verifyArgon2(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, errors.New("invalid argon2 hash")
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, fmt.Errorf("unsupported argon2 version %s", parts[2])
+	}
+	var memory, iterations uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &iterations, &threads); err != nil {
+		return false, fmt.Errorf("invalid argon2 params: %w", err)
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, fmt.Errorf("decode salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, fmt.Errorf("decode key: %w", err)
+	}
+	other := argon2.IDKey([]byte(password), salt, iterations, memory, threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// UserStore provides password hashes of users for basic auth
+type UserStore interface {
+	// PasswordHash returns bcrypt or argon2id hash of user's password. It returns errors.NotExist if user doesn't exist
+	PasswordHash(ctx context.Context, user string) (string, error)
+}
+
+// MapUserStore maps user to password hash
+type MapUserStore map[string]string
+
+var _ UserStore = MapUserStore(nil)
+
+func (s MapUserStore) PasswordHash(ctx context.Context, user string) (string, error) {
+	h, ok := s[user]
+	if !ok {
+		return "", errors.NotExist
+	}
+	return h, nil
+}
+
+// HtpasswdStore loads users from htpasswd file, which contains lines of user:hash.
+// Only bcrypt and argon2id hashes are supported, e.g. generated by htpasswd -B
+type HtpasswdStore struct {
+	filename string
+
+	mu      sync.RWMutex
+	users   map[string]string
+	modTime time.Time
+}
+
+var _ UserStore = (*HtpasswdStore)(nil)
+
+func LoadHtpasswd(filename string) (*HtpasswdStore, error) {
+	s := &HtpasswdStore{filename: filename}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *HtpasswdStore) PasswordHash(ctx context.Context, user string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h, ok := s.users[user]
+	if !ok {
+		return "", errors.NotExist
+	}
+	return h, nil
+}
+
+// Reload reads the file again
+func (s *HtpasswdStore) Reload() error {
+	info, err := os.Stat(s.filename)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		return err
+	}
+	users, err := parseHtpasswd(data)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", s.filename, err)
+	}
+	s.mu.Lock()
+	s.users = users
+	s.modTime = info.ModTime()
+	s.mu.Unlock()
+	return nil
+}
+
+// AutoReload reloads the file if it's modified, which is checked every interval until ctx is done
+func (s *HtpasswdStore) AutoReload(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				info, err := os.Stat(s.filename)
+				if err != nil {
+					logger.Errorf("Stat %s: %v", s.filename, err)
+					continue
+				}
+				s.mu.RLock()
+				modified := !info.ModTime().Equal(s.modTime)
+				s.mu.RUnlock()
+				if !modified {
+					continue
+				}
+				if err := s.Reload(); err != nil {
+					logger.Errorf("Reload %s: %v", s.filename, err)
+				}
+			}
+		}
+	}()
+}
+
+func parseHtpasswd(data []byte) (map[string]string, error) {
+	users := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(line, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid line %d", n)
+		}
+		user, hash := line[:i], line[i+1:]
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "$argon2id$") {
+			return nil, fmt.Errorf("unsupported hash of user %s at line %d", user, n)
+		}
+		users[user] = hash
+	}
+	return users, scanner.Err()
+}
@@ -0,0 +1,193 @@
+package wine
+
+import (
+	"context"
+	"go/token"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/ctxutil"
+	"github.com/gopub/wine/httpvalue"
+	iopkg "github.com/gopub/wine/internal/io"
+	"github.com/gopub/wine/internal/respond"
+)
+
+// Problem is the problem details of an error response defined in RFC 7807.
+// Handlers can return it as an error in order to customize type, title, etc.
+type Problem = iopkg.Problem
+
+// FieldError describes an invalid field. It can be returned by Validator, and will be listed in Problem.Errors
+type FieldError = iopkg.FieldError
+
+// ErrorRenderer converts errors into responses
+type ErrorRenderer interface {
+	RenderError(ctx context.Context, req *Request, err error) Responder
+}
+
+type ErrorRendererFunc func(ctx context.Context, req *Request, err error) Responder
+
+func (f ErrorRendererFunc) RenderError(ctx context.Context, req *Request, err error) Responder {
+	return f(ctx, req, err)
+}
+
+// ProblemRenderer renders errors into application/problem+json if the client accepts json,
+// otherwise renders them into plain text
+type ProblemRenderer struct {
+	// HideServerErrors hides details of 5xx errors in order not to leak internal information
+	HideServerErrors bool
+}
+
+var _ ErrorRenderer = (*ProblemRenderer)(nil)
+
+// problemContentTypes are offered to render problems, and plain text is preferred unless json is accepted explicitly
+var problemContentTypes = []string{httpvalue.Plain, httpvalue.ProblemJSON, httpvalue.JSON}
+
+func (r *ProblemRenderer) RenderError(_ context.Context, req *Request, err error) Responder {
+	p := NewProblem(req, err, http.StatusInternalServerError)
+	if r.HideServerErrors && p.Status >= http.StatusInternalServerError {
+		p.Detail = ""
+		p.Errors = nil
+	}
+	ct := httpvalue.NegotiateContentType(req.Header(httpvalue.Accept), problemContentTypes)
+	if ct != httpvalue.ProblemJSON && ct != httpvalue.JSON {
+		return Text(p.Status, p.Error())
+	}
+	resp := respond.JSON(p.Status, p)
+	resp.Header().Set(httpvalue.ContentType, httpvalue.ProblemJSON)
+	return resp
+}
+
+// NewProblem creates problem details of err for req.
+// defaultStatus is used if err doesn't carry a valid http status code.
+func NewProblem(req *Request, err error, defaultStatus int) *Problem {
+	p := new(Problem)
+	var ep *Problem
+	if errors.As(err, &ep) {
+		*p = *ep
+	} else {
+		p.Detail = err.Error()
+	}
+	code := errors.GetCode(err)
+	if p.Status == 0 {
+		p.Status = defaultStatus
+		if httpvalue.IsValidStatus(code) {
+			p.Status = code
+		}
+	}
+	if p.Code == 0 && code != p.Status {
+		p.Code = code
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	if p.Instance == "" && req.request != nil {
+		p.Instance = req.request.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = req.Header(httpvalue.RequestID)
+	}
+	return p
+}
+
+// newValidationProblem converts the error of binding or validating model into problem with invalid fields.
+// It returns err if no invalid field is found
+func newValidationProblem(err error, model reflect.Type) error {
+	if err == nil {
+		return nil
+	}
+	var p *Problem
+	if errors.As(err, &p) {
+		return err
+	}
+	if fe := fieldErrors(err, model); len(fe) > 0 {
+		return &Problem{Status: http.StatusBadRequest, Detail: err.Error(), Errors: fe}
+	}
+	return err
+}
+
+// fieldErrors extracts the invalid field from err.
+// Validate wraps the error of a nested field with its name, e.g. Address:City:empty,
+// which is converted into FieldError{Field: "address.city", Message: "empty"} according to json tags of model.
+func fieldErrors(err error, model reflect.Type) []*FieldError {
+	var names []string
+	for {
+		if fe, ok := err.(*FieldError); ok {
+			if fe.Field != "" {
+				names = append(names, fe.Field)
+			}
+			return []*FieldError{{Field: strings.Join(names, "."), Message: fe.Message}}
+		}
+		inner := errors.Unwrap(err)
+		if inner == nil {
+			break
+		}
+		name := strings.TrimSuffix(err.Error(), ":"+inner.Error())
+		if token.IsIdentifier(name) && token.IsExported(name) {
+			name, model = jsonFieldName(model, name)
+			names = append(names, name)
+		}
+		err = inner
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	return []*FieldError{{Field: strings.Join(names, "."), Message: err.Error()}}
+}
+
+// jsonFieldName returns json name and type of the struct field
+func jsonFieldName(t reflect.Type, name string) (string, reflect.Type) {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return name, nil
+	}
+	f, ok := t.FieldByName(name)
+	if !ok {
+		return name, nil
+	}
+	tag := f.Tag.Get("json")
+	if i := strings.Index(tag, ","); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag != "" && tag != "-" {
+		name = tag
+	}
+	return name, f.Type
+}
+
+// renderError converts err into response with ErrorRenderer.
+// Errors of reading streaming bodies beyond the size limit are converted into 413
+func (s *Server) renderError(ctx context.Context, req *Request, err error) Responder {
+	if !httpvalue.IsValidStatus(errors.GetCode(err)) && iopkg.IsBodyTooLarge(err) {
+		err = errors.Format(http.StatusRequestEntityTooLarge, "%v", err)
+	}
+	if s.ErrorRenderer != nil {
+		return s.ErrorRenderer.RenderError(ctx, req, err)
+	}
+	r := &ProblemRenderer{HideServerErrors: s.HideServerErrors}
+	return r.RenderError(ctx, req, err)
+}
+
+// errorResponder is returned by Error, the server renders err with ErrorRenderer
+type errorResponder struct {
+	err error
+}
+
+// Respond renders err with server's ErrorRenderer if it's not resolved by server, e.g. it's responded inside
+// http handlers wrapped by Handle. Otherwise it writes err as plain text
+func (r *errorResponder) Respond(ctx context.Context, w http.ResponseWriter) {
+	if render, ok := ctx.Value(ctxutil.KeyErrorRenderer).(func(ctx context.Context, err error) Responder); ok {
+		// Prevent rendering recursively if the renderer returns errorResponder
+		ctx = context.WithValue(ctx, ctxutil.KeyErrorRenderer, nil)
+		render(ctx, r.err).Respond(ctx, w)
+		return
+	}
+	if s := errors.GetCode(r.err); httpvalue.IsValidStatus(s) {
+		Text(s, r.err.Error()).Respond(ctx, w)
+		return
+	}
+	Text(http.StatusInternalServerError, r.err.Error()).Respond(ctx, w)
+}
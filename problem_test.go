@@ -0,0 +1,149 @@
+package wine_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	gerrors "github.com/gopub/errors"
+	"github.com/gopub/wine"
+	"github.com/stretchr/testify/require"
+)
+
+type testAddress struct {
+	City string `json:"city"`
+}
+
+func (a *testAddress) Validate() error {
+	if a.City == "" {
+		return &wine.FieldError{Field: "city", Message: "empty"}
+	}
+	return nil
+}
+
+type testProfile struct {
+	Name    string       `json:"name"`
+	Address *testAddress `json:"address"`
+}
+
+func TestServer_Problem(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.Get("/not-found", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Error(gerrors.NotFound("no item"))
+	})
+	server.Get("/internal", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Error(errors.New("db password is wrong"))
+	})
+	server.Get("/wrapped", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Error(gerrors.Wrapf(errors.New("db down"), "Save"))
+	})
+	server.Post("/profiles", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	}).SetModel(testProfile{})
+	server.Use(func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Handle(req.Request(), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			wine.Next(ctx, req).Respond(ctx, w)
+		}))
+	}).Get("/handled", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Error(gerrors.NotFound("no item"))
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, path, accept string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		req.Header.Set("X-Request-Id", "r1")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	t.Run("JSON", func(t *testing.T) {
+		resp, body := get(t, "/not-found", "application/json")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		var p wine.Problem
+		require.NoError(t, json.Unmarshal(body, &p))
+		require.Equal(t, wine.Problem{
+			Title:     "Not Found",
+			Status:    http.StatusNotFound,
+			Detail:    "no item",
+			Instance:  "/not-found",
+			RequestID: "r1",
+		}, p)
+	})
+
+	t.Run("Text", func(t *testing.T) {
+		resp, body := get(t, "/not-found", "text/html")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "no item", string(body))
+	})
+
+	t.Run("Negotiation", func(t *testing.T) {
+		_, body := get(t, "/not-found", "*/*")
+		require.Equal(t, "no item", string(body))
+		_, body = get(t, "/not-found", "text/plain, application/json;q=0")
+		require.Equal(t, "no item", string(body))
+		resp, _ := get(t, "/not-found", "text/plain;q=0.5, application/*")
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("WrappedServerError", func(t *testing.T) {
+		resp, body := get(t, "/wrapped", "application/json")
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		var p wine.Problem
+		require.NoError(t, json.Unmarshal(body, &p))
+		require.Empty(t, p.Errors)
+	})
+
+	t.Run("WrappedResponder", func(t *testing.T) {
+		resp, _ := get(t, "/handled", "application/json")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	})
+
+	t.Run("HideServerErrors", func(t *testing.T) {
+		_, body := get(t, "/internal", "application/json")
+		require.Contains(t, string(body), "db password")
+
+		server.HideServerErrors = true
+		defer func() {
+			server.HideServerErrors = false
+		}()
+		resp, body := get(t, "/internal", "application/json")
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		require.NotContains(t, string(body), "db password")
+	})
+
+	t.Run("FieldErrors", func(t *testing.T) {
+		c := server.Client()
+		err := c.Post(context.Background(), url+"/profiles", map[string]interface{}{
+			"name":    "tom",
+			"address": map[string]interface{}{},
+		}, nil)
+		var p *wine.Problem
+		require.True(t, errors.As(err, &p))
+		require.Equal(t, http.StatusBadRequest, p.Status)
+		require.Equal(t, []*wine.FieldError{{Field: "address.city", Message: "empty"}}, p.Errors)
+		require.Equal(t, http.StatusBadRequest, gerrors.GetCode(err))
+	})
+
+	t.Run("Renderer", func(t *testing.T) {
+		server.ErrorRenderer = wine.ErrorRendererFunc(func(ctx context.Context, req *wine.Request, err error) wine.Responder {
+			return wine.Text(gerrors.GetCode(err), "custom")
+		})
+		defer func() {
+			server.ErrorRenderer = nil
+		}()
+		resp, body := get(t, "/not-found", "application/json")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, "custom", string(body))
+	})
+}
@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/ctxutil"
+	"github.com/gopub/wine/httpvalue"
+)
+
+// KeyFunc returns the key which identifies the client of req
+type KeyFunc func(ctx context.Context, req *wine.Request) string
+
+// KeyByIP identifies clients by remote address
+func KeyByIP(ctx context.Context, req *wine.Request) string {
+	addr := req.Request().RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if addr == "" {
+		return ""
+	}
+	return "ip:" + addr
+}
+
+// KeyByForwardedIP identifies clients by X-Forwarded-For or X-Real-Ip, then remote address.
+// It should be used only behind trusted proxies, as headers can be forged by clients.
+func KeyByForwardedIP(ctx context.Context, req *wine.Request) string {
+	if s := req.Header("X-Forwarded-For"); s != "" {
+		if ip := strings.TrimSpace(strings.Split(s, ",")[0]); ip != "" {
+			return "ip:" + ip
+		}
+	}
+	if ip := strings.TrimSpace(req.Header("X-Real-Ip")); ip != "" {
+		return "ip:" + ip
+	}
+	return KeyByIP(ctx, req)
+}
+
+// KeyByUserID identifies authenticated clients by user id
+func KeyByUserID(ctx context.Context, req *wine.Request) string {
+	id := req.UserID()
+	if id == 0 {
+		id = ctxutil.GetUserID(ctx)
+	}
+	if id == 0 {
+		return ""
+	}
+	return "user:" + strconv.FormatInt(id, 10)
+}
+
+// KeyByDeviceID identifies clients by X-Wine-Device-Id
+func KeyByDeviceID(ctx context.Context, req *wine.Request) string {
+	if id := req.Header(httpvalue.CustomDeviceID); id != "" {
+		return "device:" + id
+	}
+	return ""
+}
+
+// KeyByAppID identifies clients by X-Wine-App-Id
+func KeyByAppID(ctx context.Context, req *wine.Request) string {
+	if id := req.Header(httpvalue.CustomAppID); id != "" {
+		return "app:" + id
+	}
+	return ""
+}
+
+// FirstKey returns the first non-empty key of funcs, e.g. FirstKey(KeyByUserID, KeyByIP)
+func FirstKey(funcs ...KeyFunc) KeyFunc {
+	return func(ctx context.Context, req *wine.Request) string {
+		for _, f := range funcs {
+			if k := f(ctx, req); k != "" {
+				return k
+			}
+		}
+		return ""
+	}
+}
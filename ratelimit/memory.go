@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps quotas in memory. It's only suitable for single instance deployment
+type MemoryStore struct {
+	mu        sync.Mutex
+	quotas    map[string]*quota
+	purgedAt  time.Time
+	purgeFreq time.Duration
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+type quota struct {
+	// token bucket
+	tokens    float64
+	updatedAt time.Time
+
+	// sliding window
+	window int64
+	curr   int
+	prev   int
+
+	expireAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		quotas:    make(map[string]*quota),
+		purgeFreq: time.Minute,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rule *Rule) (*Result, error) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.purge(now)
+	q := s.quotas[key]
+	if q == nil {
+		q = new(quota)
+		s.quotas[key] = q
+	}
+	if rule.Algorithm == SlidingWindow {
+		return q.takeWindow(rule, now), nil
+	}
+	return q.takeToken(rule, now), nil
+}
+
+// purge removes expired quotas periodically
+func (s *MemoryStore) purge(now time.Time) {
+	if now.Sub(s.purgedAt) < s.purgeFreq {
+		return
+	}
+	s.purgedAt = now
+	for k, q := range s.quotas {
+		if now.After(q.expireAt) {
+			delete(s.quotas, k)
+		}
+	}
+}
+
+func (q *quota) takeToken(rule *Rule, now time.Time) *Result {
+	limit := float64(rule.Limit)
+	// tokens per nanosecond
+	rate := limit / float64(rule.Period)
+	if q.updatedAt.IsZero() {
+		q.tokens = limit
+	} else {
+		q.tokens = math.Min(limit, q.tokens+float64(now.Sub(q.updatedAt))*rate)
+	}
+	q.updatedAt = now
+
+	res := &Result{Limit: rule.Limit}
+	if q.tokens >= 1 {
+		q.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration((1 - q.tokens) / rate)
+	}
+	res.Remaining = int(q.tokens)
+	res.Reset = time.Duration((limit - q.tokens) / rate)
+	q.expireAt = now.Add(res.Reset)
+	return res
+}
+
+func (q *quota) takeWindow(rule *Rule, now time.Time) *Result {
+	period := int64(rule.Period)
+	window := now.UnixNano() / period
+	switch window {
+	case q.window:
+	case q.window + 1:
+		q.prev, q.curr = q.curr, 0
+	default:
+		q.prev, q.curr = 0, 0
+	}
+	q.window = window
+	elapsed := now.UnixNano() - window*period
+	left := period - elapsed
+	count := float64(q.prev)*float64(left)/float64(period) + float64(q.curr)
+
+	res := &Result{Limit: rule.Limit}
+	if count+1 <= float64(rule.Limit) {
+		q.curr++
+		count++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(windowRetryAfter(rule.Limit, q.curr, q.prev, left, period))
+	}
+	if remaining := rule.Limit - int(math.Ceil(count)); remaining > 0 {
+		res.Remaining = remaining
+	}
+	res.Reset = time.Duration(left)
+	q.expireAt = now.Add(time.Duration(left + period))
+	return res
+}
+
+// windowRetryAfter returns the duration until weighted count drops below limit
+func windowRetryAfter(limit, curr, prev int, left, period int64) int64 {
+	// Wait for previous window's weight to decrease in current window
+	if prev > 0 && curr+1 <= limit {
+		return left - int64(float64(limit-1-curr)*float64(period)/float64(prev))
+	}
+	// Wait for current window to be the previous one
+	wait := period - int64(float64(limit-1)*float64(period)/float64(curr))
+	if wait < 0 {
+		wait = 0
+	}
+	return left + wait
+}
@@ -0,0 +1,159 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gopub/environ"
+	"github.com/gopub/errors"
+	"github.com/gopub/log/v2"
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/httpvalue"
+)
+
+var logger = wine.Logger()
+
+// Algorithm decides how requests are counted
+type Algorithm int
+
+const (
+	// TokenBucket refills Limit tokens evenly in Period, which allows bursts of at most Limit requests
+	TokenBucket Algorithm = iota
+	// SlidingWindow allows at most Limit requests in any Period.
+	// It's approximated by weighting the count of previous fixed window.
+	SlidingWindow
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case TokenBucket:
+		return "token_bucket"
+	case SlidingWindow:
+		return "sliding_window"
+	default:
+		return fmt.Sprintf("algorithm(%d)", int(a))
+	}
+}
+
+// Rule limits requests of a key to Limit in Period
+type Rule struct {
+	Algorithm Algorithm     `json:"algorithm"`
+	Limit     int           `json:"limit"`
+	Period    time.Duration `json:"period"`
+}
+
+// Result is the quota of a key after taking a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the duration until quota is restored
+	Reset time.Duration
+	// RetryAfter is the duration to wait before next request is allowed, it's zero if Allowed is true
+	RetryAfter time.Duration
+}
+
+// Store keeps quotas of keys
+type Store interface {
+	// Take counts a request of key, and reports whether it's allowed by rule
+	Take(ctx context.Context, key string, rule *Rule) (*Result, error)
+}
+
+type Options struct {
+	Rule
+	// Name separates quotas of handlers sharing the same store
+	Name string
+	// Key identifies clients, requests with empty key are not limited
+	Key KeyFunc
+	// PerEndpoint counts requests of each endpoint separately, e.g. limiter is used by a router with many endpoints
+	PerEndpoint bool
+	// FailOpen allows requests if store fails, otherwise responds errors
+	FailOpen bool
+}
+
+func DefaultOptions() *Options {
+	return &Options{
+		Rule: Rule{
+			Algorithm: TokenBucket,
+			Limit:     environ.Int("wine.ratelimit.limit", 60),
+			Period:    environ.Duration("wine.ratelimit.period", time.Minute),
+		},
+		Name: "ratelimit",
+		Key:  KeyByIP,
+	}
+}
+
+// NewHandler returns an interceptor which responds 429 if the client has run out of quota.
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy are added to responses,
+// and Retry-After is added to 429 responses.
+// Quotas are kept in memory if store is nil.
+func NewHandler(store Store, options *Options) wine.HandlerFunc {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	if options == nil {
+		options = DefaultOptions()
+	}
+	if options.Limit <= 0 {
+		logger.Panic("Rate limit must be positive")
+	}
+	if options.Period < time.Second {
+		logger.Panic("Rate limit period cannot be less than 1 second")
+	}
+	if options.Algorithm != TokenBucket && options.Algorithm != SlidingWindow {
+		logger.Panicf("Unsupported rate limit algorithm: %v", options.Algorithm)
+	}
+
+	rule := options.Rule
+	keyFunc := options.Key
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+	policy := fmt.Sprintf("%d;w=%d", rule.Limit, seconds(rule.Period))
+
+	return func(ctx context.Context, req *wine.Request) wine.Responder {
+		key := keyFunc(ctx, req)
+		if key == "" {
+			return wine.Next(ctx, req)
+		}
+		if options.PerEndpoint && req.Endpoint() != nil {
+			key = req.Request().Method + ":" + req.Endpoint().Path() + ":" + key
+		}
+		if options.Name != "" {
+			key = options.Name + ":" + key
+		}
+
+		res, err := store.Take(ctx, key, &rule)
+		if err != nil {
+			if options.FailOpen {
+				log.FromContext(ctx).Errorf("Take rate limit quota of %s: %v", key, err)
+				return wine.Next(ctx, req)
+			}
+			return wine.Error(fmt.Errorf("take rate limit quota: %w", err))
+		}
+
+		h := http.Header{}
+		h.Set(httpvalue.RateLimitLimit, strconv.Itoa(res.Limit))
+		h.Set(httpvalue.RateLimitRemaining, strconv.Itoa(res.Remaining))
+		h.Set(httpvalue.RateLimitReset, strconv.FormatInt(seconds(res.Reset), 10))
+		h.Set(httpvalue.RateLimitPolicy, policy)
+		if !res.Allowed {
+			retryAfter := seconds(res.RetryAfter)
+			h.Set(httpvalue.RetryAfter, strconv.FormatInt(retryAfter, 10))
+			return wine.WithHeader(wine.Error(errors.Format(http.StatusTooManyRequests, "Rate limit exceeded, retry after %d seconds", retryAfter)), h)
+		}
+		return wine.WithHeader(wine.Next(ctx, req), h)
+	}
+}
+
+// seconds rounds d up to seconds
+func seconds(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	return int64(math.Ceil(d.Seconds()))
+}
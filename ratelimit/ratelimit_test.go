@@ -0,0 +1,99 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/ratelimit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	server := wine.NewTestServer(t)
+	hello := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, "hello")
+	}
+	server.Use(ratelimit.NewHandler(nil, &ratelimit.Options{
+		Rule: ratelimit.Rule{Algorithm: ratelimit.TokenBucket, Limit: 3, Period: time.Minute},
+		Key:  ratelimit.KeyByIP,
+	})).Get("/bucket", hello)
+	r := server.Use(ratelimit.NewHandler(nil, &ratelimit.Options{
+		Rule:        ratelimit.Rule{Algorithm: ratelimit.SlidingWindow, Limit: 2, Period: time.Minute},
+		Key:         ratelimit.KeyByDeviceID,
+		PerEndpoint: true,
+	}))
+	r.Get("/window/a", hello)
+	r.Get("/window/b", hello)
+	url := server.Run()
+
+	get := func(t *testing.T, path, deviceID string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		if deviceID != "" {
+			req.Header.Set("X-Wine-Device-Id", deviceID)
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("TokenBucket", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp := get(t, "/bucket", "")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Equal(t, "3", resp.Header.Get("RateLimit-Limit"))
+			require.Equal(t, "3;w=60", resp.Header.Get("RateLimit-Policy"))
+			require.Equal(t, []string{"2", "1", "0"}[i], resp.Header.Get("RateLimit-Remaining"))
+		}
+		resp := get(t, "/bucket", "")
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+		require.Equal(t, "20", resp.Header.Get("Retry-After"))
+		require.Equal(t, "0", resp.Header.Get("RateLimit-Remaining"))
+	})
+
+	t.Run("SlidingWindow", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, get(t, "/window/a", "d1").StatusCode)
+		require.Equal(t, http.StatusOK, get(t, "/window/a", "d1").StatusCode)
+		resp := get(t, "/window/a", "d1")
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get("Retry-After"))
+
+		require.Equal(t, http.StatusOK, get(t, "/window/a", "d2").StatusCode)
+		require.Equal(t, http.StatusOK, get(t, "/window/b", "d1").StatusCode)
+	})
+
+	t.Run("EmptyKey", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp := get(t, "/window/a", "")
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Empty(t, resp.Header.Get("RateLimit-Limit"))
+		}
+	})
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	s := ratelimit.NewMemoryStore()
+	ctx := context.Background()
+	rule := &ratelimit.Rule{Algorithm: ratelimit.SlidingWindow, Limit: 2, Period: time.Second}
+	for i := 0; i < 2; i++ {
+		res, err := s.Take(ctx, "k", rule)
+		require.NoError(t, err)
+		require.True(t, res.Allowed)
+		require.Equal(t, 1-i, res.Remaining)
+	}
+	res, err := s.Take(ctx, "k", rule)
+	require.NoError(t, err)
+	require.False(t, res.Allowed)
+	require.True(t, res.RetryAfter > 0 && res.RetryAfter <= 2*time.Second)
+
+	time.Sleep(res.RetryAfter + 10*time.Millisecond)
+	res, err = s.Take(ctx, "k", rule)
+	require.NoError(t, err)
+	require.True(t, res.Allowed)
+}
@@ -0,0 +1,10 @@
+module github.com/gopub/wine/ratelimit/store
+
+go 1.16
+
+require (
+	github.com/go-redis/redis v6.15.9+incompatible
+	github.com/gopub/wine v1.49.0
+	github.com/onsi/ginkgo v1.15.0 // indirect
+	github.com/onsi/gomega v1.10.5 // indirect
+)
@@ -0,0 +1,119 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/gopub/wine/ratelimit"
+)
+
+// tokenBucketScript refills tokens by elapsed milliseconds, then takes one token.
+// It returns allowed, remaining, reset and retry after in milliseconds.
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local rate = limit / period
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = limit
+else
+	tokens = math.min(limit, tokens + math.max(0, now - ts) * rate)
+end
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+local reset = math.ceil((limit - tokens) / rate)
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.max(reset, 1))
+return {allowed, math.floor(tokens), reset, retry}
+`)
+
+// slidingWindowScript counts requests of current and previous fixed windows, then weights the previous count.
+// KEYS[1] and KEYS[2] are keys of current and previous windows.
+// It returns allowed, remaining, reset and retry after in milliseconds.
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local window = math.floor(now / period)
+local left = period - (now - window * period)
+local currKey = KEYS[1]
+local curr = tonumber(redis.call('GET', currKey) or '0')
+local prev = tonumber(redis.call('GET', KEYS[2]) or '0')
+local count = prev * left / period + curr
+local allowed = 0
+local retry = 0
+if count + 1 <= limit then
+	redis.call('INCR', currKey)
+	redis.call('PEXPIRE', currKey, period * 2)
+	count = count + 1
+	allowed = 1
+elseif prev > 0 and curr + 1 <= limit then
+	retry = left - math.floor((limit - 1 - curr) * period / prev)
+else
+	retry = left + math.max(0, period - math.floor((limit - 1) * period / curr))
+end
+return {allowed, math.max(0, limit - math.ceil(count)), left, retry}
+`)
+
+// Store keeps quotas in redis, which can be shared by multiple instances
+type Store struct {
+	c *redis.Client
+}
+
+var _ ratelimit.Store = (*Store)(nil)
+
+func NewStore(c *redis.Client) *Store {
+	return &Store{
+		c: c,
+	}
+}
+
+func (s *Store) Take(ctx context.Context, key string, rule *ratelimit.Rule) (*ratelimit.Result, error) {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	script := tokenBucketScript
+	keys := []string{key}
+	if rule.Algorithm == ratelimit.SlidingWindow {
+		script = slidingWindowScript
+		keys = windowKeys(key, now, rule.Period.Milliseconds())
+	}
+	v, err := script.Run(s.c.WithContext(ctx), keys, rule.Limit, rule.Period.Milliseconds(), now).Result()
+	if err != nil {
+		return nil, err
+	}
+	l, ok := v.([]interface{})
+	if !ok || len(l) != 4 {
+		return nil, fmt.Errorf("unexpected script result: %v", v)
+	}
+	var a [4]int64
+	for i, e := range l {
+		if a[i], ok = e.(int64); !ok {
+			return nil, fmt.Errorf("unexpected script result: %v", v)
+		}
+	}
+	return &ratelimit.Result{
+		Allowed:    a[0] == 1,
+		Limit:      rule.Limit,
+		Remaining:  int(a[1]),
+		Reset:      time.Duration(a[2]) * time.Millisecond,
+		RetryAfter: time.Duration(a[3]) * time.Millisecond,
+	}, nil
+}
+
+// windowKeys returns keys of current and previous windows.
+// They share the same hash tag so that they are in the same slot of redis cluster.
+func windowKeys(key string, now, period int64) []string {
+	window := now / period
+	tag := "{" + key + "}"
+	return []string{fmt.Sprintf("%s:%d", tag, window), fmt.Sprintf("%s:%d", tag, window-1)}
+}
@@ -1,9 +1,11 @@
 package wine
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"reflect"
@@ -26,6 +28,7 @@ type Request struct {
 	groupedParams *GroupedParams
 	params        types.M
 	body          []byte
+	streaming     bool
 	contentType   string
 	Model         interface{}
 
@@ -48,19 +51,60 @@ func (r *Request) GroupedParams() *GroupedParams {
 	return r.groupedParams
 }
 
+// setHostParams sets params in host, e.g. tenant in {tenant}.example.com
+func (r *Request) setHostParams(p map[string]string) {
+	r.groupedParams.HostParams = types.M{}
+	for k, v := range p {
+		r.groupedParams.HostParams[k] = v
+		r.params[k] = v
+	}
+}
+
+// setPathParams sets path params, which are converted into typed values if they have constraints, e.g. {id:int}
 func (r *Request) setPathParams(p map[string]string) {
 	r.groupedParams.PathParams = types.M{}
+	var typed map[string]interface{}
+	if r.endpoint != nil {
+		typed = r.endpoint.ConvertParams(p)
+	}
 	for k, v := range p {
-		r.groupedParams.PathParams[k] = v
-		r.params[k] = v
+		var tv interface{} = v
+		if typed != nil {
+			tv = typed[k]
+		}
+		r.groupedParams.PathParams[k] = tv
+		r.params[k] = tv
 	}
 }
 
-// Body returns request body
+// Body returns request body. It's always nil if the endpoint streams request body
 func (r *Request) Body() []byte {
 	return r.body
 }
 
+// BodyReader returns a reader of request body.
+// For endpoints with streaming body, it reads from the connection directly and can be read only once.
+func (r *Request) BodyReader() io.Reader {
+	if r.streaming {
+		return r.request.Body
+	}
+	return bytes.NewReader(r.body)
+}
+
+// MultipartReader returns an iterator of multipart/form-data parts, e.g. streaming uploaded files into storage.
+// It's only available for endpoints with streaming body, as the form has been parsed for others.
+func (r *Request) MultipartReader() (*multipart.Reader, error) {
+	if !r.streaming {
+		return nil, errors.New("request body is not streaming")
+	}
+	return r.request.MultipartReader()
+}
+
+// IsStreaming reports whether request body is left unread for handlers
+func (r *Request) IsStreaming() bool {
+	return r.streaming
+}
+
 // ContentType returns request's content type
 func (r *Request) ContentType() string {
 	return r.contentType
@@ -98,13 +142,19 @@ func (r *Request) BasicAccount() (user string, password string) {
 		logger.Errorf("Decode base64 string %s: %v", l[1], err)
 		return
 	}
-	userAndPass := strings.Split(string(b), ":")
+	// Password may contain colons
+	userAndPass := strings.SplitN(string(b), ":", 2)
 	if len(userAndPass) != 2 {
 		return
 	}
 	return userAndPass[0], userAndPass[1]
 }
 
+// Endpoint returns the matched endpoint, it's nil if no endpoint matches the request
+func (r *Request) Endpoint() *Endpoint {
+	return r.endpoint
+}
+
 func (r *Request) NormalizedPath() string {
 	return router.Normalize(r.request.URL.Path)
 }
@@ -117,14 +167,14 @@ func (r *Request) bind(m interface{}) error {
 			return errors.BadRequest("cannot unmarshal protobuf message: %v", err)
 		}
 		r.Model = pv.Interface()
-		return Validate(r.Model)
+		return newValidationProblem(Validate(r.Model), reflect.TypeOf(m))
 	}
 
 	pv := reflect.New(reflect.TypeOf(m))
 	err := conv.Assign(pv.Interface(), r.params)
 	if err == nil {
 		r.Model = pv.Elem().Interface()
-		return Validate(r.Model)
+		return newValidationProblem(Validate(r.Model), reflect.TypeOf(m))
 	}
 
 	// if the model is not struct or map, e.g. it's int, float, string etc.
@@ -134,9 +184,12 @@ func (r *Request) bind(m interface{}) error {
 	if kind != reflect.Struct && kind != reflect.Map && len(r.groupedParams.BodyParams) == 0 {
 		if p := getSingleParam(r); p != nil && conv.Assign(pv.Interface(), p) == nil {
 			r.Model = pv.Elem().Interface()
-			return Validate(r.Model)
+			return newValidationProblem(Validate(r.Model), reflect.TypeOf(m))
 		}
 	}
+	if p := newValidationProblem(err, reflect.TypeOf(m)); p != err {
+		return p
+	}
 	return errors.BadRequest("cannot assign: %v", err)
 }
 
@@ -191,8 +244,32 @@ func (r *Request) SetUserID(id int64) {
 	r.uid = id
 }
 
-func parseRequest(r *http.Request, maxMem types.ByteUnit) (*Request, error) {
-	params, body, err := iopkg.ReadRequest(r, maxMem)
+// parseRequest limits body size before decompressing and reading it.
+// maxBodySize <= 0 means no limit. Body is left unread if streaming is true.
+func parseRequest(w http.ResponseWriter, r *http.Request, maxBodySize int64, streaming bool, o *Options) (*Request, error) {
+	if maxBodySize > 0 {
+		if err := iopkg.LimitRequestBody(w, r, maxBodySize); err != nil {
+			return nil, err
+		}
+	}
+	maxDecompressedSize := int64(o.MaxDecompressedSize)
+	if streaming {
+		maxDecompressedSize = maxBodySize
+	}
+	if err := iopkg.DecompressRequest(r, maxDecompressedSize); err != nil {
+		return nil, err
+	}
+	if streaming {
+		params := iopkg.ReadParams(r)
+		return &Request{
+			request:       r,
+			groupedParams: params,
+			params:        params.Combine(),
+			streaming:     true,
+			contentType:   httpvalue.GetContentType(r.Header),
+		}, nil
+	}
+	params, body, err := iopkg.ReadRequest(r, o.ReqFormMem)
 	if err != nil {
 		return nil, fmt.Errorf("read request: %w", err)
 	}
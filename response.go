@@ -8,7 +8,6 @@ import (
 
 	"github.com/gopub/errors"
 	"github.com/gopub/wine/ctxutil"
-	"github.com/gopub/wine/httpvalue"
 	iopkg "github.com/gopub/wine/internal/io"
 	"github.com/gopub/wine/internal/respond"
 	"google.golang.org/protobuf/proto"
@@ -118,14 +117,31 @@ func Handle(req *http.Request, h http.Handler) Responder {
 
 var _ Responder = (*errors.Error)(nil)
 
+// Error creates a responder which is rendered by server's ErrorRenderer.
+// Status is decided by errors.GetCode, and it's 500 if err doesn't carry a valid http status.
 func Error(err error) Responder {
 	if err == nil {
 		return OK
 	}
-	if s := errors.GetCode(err); httpvalue.IsValidStatus(s) {
-		return Text(s, err.Error())
+	return &errorResponder{err: err}
+}
+
+// WithHeader adds header to the response of r, e.g. interceptors setting header of responses returned by next handlers.
+// Errors and negotiations wrapped by it are still rendered by the server.
+func WithHeader(r Responder, header http.Header) Responder {
+	return &headerResponder{Responder: r, header: header}
+}
+
+type headerResponder struct {
+	Responder
+	header http.Header
+}
+
+func (r *headerResponder) Respond(ctx context.Context, w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
 	}
-	return Text(http.StatusInternalServerError, err.Error())
+	r.Responder.Respond(ctx, w)
 }
 
 type Result struct {
@@ -138,6 +154,9 @@ func CompressWriter(w http.ResponseWriter, encodings ...string) (http.ResponseWr
 		return nil, errors.New("cannot compress writer twice")
 	}
 	rw, _ := w.(*iopkg.ResponseWriter)
+	if aw, ok := w.(*iopkg.AutoCompressResponseWriter); ok {
+		rw = aw.Detach()
+	}
 	if rw == nil {
 		return nil, errors.New("invalid response writer")
 	}
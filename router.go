@@ -1,17 +1,29 @@
 package wine
 
 import (
+	"container/list"
 	"context"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gopub/conv"
+	"github.com/gopub/wine/openapi"
 	"github.com/gopub/wine/router"
 )
 
 type metadata struct {
-	Header *Header
+	Header      *Header
+	CORS        *CORSPolicy
+	MaxBodySize int64
+	Streaming   bool
+	Roles       []string
+	Scopes      []string
+	Authorizer  Authorizer
+	// Mounted is the handler mounted by Router.Mount
+	Mounted http.Handler
+	// FirstHandler is the element of endpoint's first own handler, which is called after interceptors
+	FirstHandler *list.Element
 }
 
 func newMetadata() *metadata {
@@ -22,7 +34,13 @@ func newMetadata() *metadata {
 
 func (m *metadata) clone() *metadata {
 	return &metadata{
-		Header: m.Header.Clone(),
+		Header:      m.Header.Clone(),
+		CORS:        m.CORS,
+		MaxBodySize: m.MaxBodySize,
+		Streaming:   m.Streaming,
+		Roles:       m.Roles,
+		Scopes:      m.Scopes,
+		Authorizer:  m.Authorizer,
 	}
 }
 
@@ -30,15 +48,87 @@ type Endpoint struct {
 	*router.Endpoint
 }
 
+// SetName names the endpoint in order to build its url by Router.URL or template function url.
+// It panics if name is used by another endpoint
+func (e *Endpoint) SetName(name string) *Endpoint {
+	e.Endpoint.SetName(name)
+	return e
+}
+
 func (e *Endpoint) Header() *Header {
 	return e.Metadata().(*metadata).Header
 }
 
+// CORSPolicy returns the CORS policy applied to the endpoint
+func (e *Endpoint) CORSPolicy() *CORSPolicy {
+	return e.Metadata().(*metadata).CORS
+}
+
+// SetCORSPolicy overrides the CORS policy inherited from router
+func (e *Endpoint) SetCORSPolicy(p *CORSPolicy) *Endpoint {
+	e.Metadata().(*metadata).CORS = p
+	return e
+}
+
+// MaxBodySize returns the max size of request body, zero means the server's default
+func (e *Endpoint) MaxBodySize() int64 {
+	return e.Metadata().(*metadata).MaxBodySize
+}
+
+// SetMaxBodySize overrides Options.MaxBodySize, e.g. larger size for uploading. Negative value means no limit
+func (e *Endpoint) SetMaxBodySize(n int64) *Endpoint {
+	e.Metadata().(*metadata).MaxBodySize = n
+	return e
+}
+
+// StreamingBody reports whether request body is left unread for handlers to stream
+func (e *Endpoint) StreamingBody() bool {
+	return e.Metadata().(*metadata).Streaming
+}
+
+// SetStreamingBody disables reading request body into memory before handling, e.g. uploading large files.
+// Handlers read the body by Request.BodyReader or Request.MultipartReader, and body params are not bound to model.
+// Options.MaxBodySize and Options.MaxDecompressedSize don't apply to streaming bodies, only SetMaxBodySize does.
+func (e *Endpoint) SetStreamingBody(b bool) *Endpoint {
+	e.Metadata().(*metadata).Streaming = b
+	return e
+}
+
+// RequireRoles requires the user to have one of roles, otherwise responds 403
+func (e *Endpoint) RequireRoles(roles ...string) *Endpoint {
+	e.Metadata().(*metadata).Roles = roles
+	return e
+}
+
+// RequiredRoles returns roles required by RequireRoles
+func (e *Endpoint) RequiredRoles() []string {
+	return e.Metadata().(*metadata).Roles
+}
+
+// RequireScopes requires the user to be granted all scopes, otherwise responds 403
+func (e *Endpoint) RequireScopes(scopes ...string) *Endpoint {
+	e.Metadata().(*metadata).Scopes = scopes
+	return e
+}
+
+// RequiredScopes returns scopes required by RequireScopes
+func (e *Endpoint) RequiredScopes() []string {
+	return e.Metadata().(*metadata).Scopes
+}
+
+func (e *Endpoint) authorizer() Authorizer {
+	if a := e.Metadata().(*metadata).Authorizer; a != nil {
+		return a
+	}
+	return DefaultAuthorizer
+}
+
 // Router implements routing function
 type Router struct {
 	*router.Router
 	authChecker Handler
 	md          *metadata
+	apiInfo     *openapi.Info
 }
 
 // NewRouter new a Router
@@ -58,13 +148,15 @@ func (r *Router) bindSysHandlers() {
 	r.Bind(http.MethodGet, versionPath, HandleResponder(Text(http.StatusOK, "v1.26.5")))
 	r.Get(uptimePath, newUptimeHandler())
 	r.Handle(echoPath, handleEcho)
+	r.Get(openAPIPath, r.handleOpenAPI)
 }
 
 // SetAuthChecker set a checker function which will fail all non-authenticated requests
 // Authentication is supposed to be done ahead of auth checker, e.g. PreHandler.
 // Some endpoints are public no matter authenticated or not, however some may need to check authentication.
 // In PreHandler, authentication may succeed or fail, it doesn't matter.
-// Regarding to authorization, it's related to business logic, so the router won't handle this.
+// Regarding to authorization, endpoints can declare required roles and scopes by RequireRoles and RequireScopes,
+// which are checked by Authorizer after interceptors.
 func (r *Router) SetAuthChecker(h Handler) {
 	r.authChecker = h
 }
@@ -82,6 +174,19 @@ func (r *Router) Group(name string) *Router {
 		Router:      nr,
 		authChecker: r.authChecker,
 		md:          r.md.clone(),
+		apiInfo:     r.apiInfo,
+	}
+}
+
+// Host returns a new router whose endpoints only serve requests to hosts matching pattern, e.g. api.example.com
+// or {tenant}.example.com. Params in host are merged into Request.Params()
+func (r *Router) Host(pattern string) *Router {
+	nr := r.Router.Host(pattern)
+	return &Router{
+		Router:      nr,
+		authChecker: r.authChecker,
+		md:          r.md.clone(),
+		apiInfo:     r.apiInfo,
 	}
 }
 
@@ -93,6 +198,7 @@ func (r *Router) UseHandlers(handlers ...Handler) *Router {
 		Router:      nr,
 		authChecker: r.authChecker,
 		md:          r.md.clone(),
+		apiInfo:     r.apiInfo,
 	}
 }
 
@@ -103,12 +209,24 @@ func (r *Router) Use(funcs ...HandlerFunc) *Router {
 		Router:      nr,
 		authChecker: r.authChecker,
 		md:          r.md.clone(),
+		apiInfo:     r.apiInfo,
 	}
 }
 
 // bind binds method, path with handlers
 func (r *Router) Bind(method, path string, handlers ...Handler) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(method, path, conv.ToList(handlers)))
+	return r.bind(method, path, conv.ToList(handlers))
+}
+
+// bind binds handlers following the interceptors added by Use.
+// Endpoint's metadata is cloned from r once here, so that it's read-only while serving requests
+func (r *Router) bind(method, path string, handlers *list.List) *Endpoint {
+	first := handlers.Front()
+	e := r.Router.Bind(method, path, handlers)
+	md := r.md.clone()
+	md.FirstHandler = first
+	e.SetMetadata(md)
+	return r.toEndpoint(e)
 }
 
 // StaticFile binds path to a file
@@ -170,74 +288,150 @@ func (r *Router) StaticFS(path string, fs http.FileSystem) {
 	})
 }
 
+// RouteLister lists routes, e.g. *Server and *websocket.Server.
+// Routes of mounted handlers which implement RouteLister are shown in endpoint listings
+type RouteLister interface {
+	ListRoutes() []*router.Endpoint
+}
+
+var _ RouteLister = (*Server)(nil)
+
+// Mount binds h to prefix with any method, e.g. *Server, *websocket.Server or any http.Handler.
+// Prefix is stripped from Path and RawPath of requests before passing to h, and handlers added by Use are applied ahead.
+// Request context of h carries values set by these handlers and the server's timeout.
+// Request body is left unread for h, so that h decides how to read and limit it
+func (r *Router) Mount(prefix string, h http.Handler) *Endpoint {
+	if h == nil {
+		logger.Panic("handler is nil")
+	}
+	full := router.Normalize(r.BasePath() + "/" + prefix)
+	n := 0
+	if full != "" {
+		n = len(strings.Split(full, "/"))
+	}
+	stripped := stripSegments(n, h)
+	e := r.Handle(router.Normalize(prefix+"/*"), func(ctx context.Context, req *Request) Responder {
+		return Handle(req.request.WithContext(ctx), stripped)
+	})
+	// Body is read and limited by the mounted handler
+	e.SetStreamingBody(true)
+	e.Metadata().(*metadata).Mounted = h
+	return e
+}
+
 // Handle binds funcs to path with any(wildcard) method
 func (r *Router) Handle(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind("", path, conv.ToList(funcs)))
+	return r.bind("", path, conv.ToList(funcs))
 }
 
 // Get binds funcs to path with GET method
 func (r *Router) Get(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodGet, path, conv.ToList(funcs)))
+	return r.bind(http.MethodGet, path, conv.ToList(funcs))
 }
 
 // Post binds funcs to path with POST method
 func (r *Router) Post(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodPost, path, conv.ToList(funcs)))
+	return r.bind(http.MethodPost, path, conv.ToList(funcs))
 }
 
 // Put binds funcs to path with PUT method
 func (r *Router) Put(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodPut, path, conv.ToList(funcs)))
+	return r.bind(http.MethodPut, path, conv.ToList(funcs))
 }
 
 // Patch binds funcs to path with PATCH method
 func (r *Router) Patch(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodPatch, path, conv.ToList(funcs)))
+	return r.bind(http.MethodPatch, path, conv.ToList(funcs))
 }
 
 // Delete binds funcs to path with DELETE method
 func (r *Router) Delete(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodDelete, path, conv.ToList(funcs)))
+	return r.bind(http.MethodDelete, path, conv.ToList(funcs))
 }
 
 // Options binds funcs to path with OPTIONS method
 func (r *Router) Options(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodOptions, path, conv.ToList(funcs)))
+	return r.bind(http.MethodOptions, path, conv.ToList(funcs))
 }
 
 // Head binds funcs to path with HEAD method
 func (r *Router) Head(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodHead, path, conv.ToList(funcs)))
+	return r.bind(http.MethodHead, path, conv.ToList(funcs))
 }
 
 // Trace binds funcs to path with TRACE method
 func (r *Router) Trace(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodTrace, path, conv.ToList(funcs)))
+	return r.bind(http.MethodTrace, path, conv.ToList(funcs))
 }
 
 // Connect binds funcs to path with CONNECT method
 func (r *Router) Connect(path string, funcs ...HandlerFunc) *Endpoint {
-	return r.toEndpoint(r.Router.Bind(http.MethodConnect, path, conv.ToList(funcs)))
+	return r.bind(http.MethodConnect, path, conv.ToList(funcs))
 }
 
-func (r *Router) listEndpoints(ctx context.Context, req *Request) Responder {
-	var l []*router.Endpoint
-	maxLenOfPath := 0
-	all := req.Params().Bool("all")
-	for _, node := range r.ListRoutes() {
-		if !all && reservedPaths[node.Path()] {
+// listedRoute is a route in endpoint listings, path includes mount prefixes of routes of mounted handlers
+type listedRoute struct {
+	*router.Endpoint
+	host string
+	path string
+}
+
+// listRoutes lists routes with mount prefix, and routes of mounted handlers which implement RouteLister
+func listRoutes(host, prefix string, routes []*router.Endpoint, all bool) []*listedRoute {
+	var l []*listedRoute
+	for _, e := range routes {
+		if !all && reservedPaths[e.Path()] {
 			continue
 		}
-		l = append(l, node)
-		if n := len(node.Path()); n > maxLenOfPath {
-			maxLenOfPath = n
+		h := e.Host
+		if h == "" {
+			h = host
+		}
+		p := router.Normalize(prefix + "/" + e.Path())
+		l = append(l, &listedRoute{Endpoint: e, host: h, path: p})
+		if md, ok := e.Metadata().(*metadata); ok {
+			if rl, ok := md.Mounted.(RouteLister); ok {
+				l = append(l, listRoutes(h, strings.TrimSuffix(p, "/*"), rl.ListRoutes(), all)...)
+			}
+		}
+	}
+	return l
+}
+
+func (r *Router) listEndpoints(ctx context.Context, req *Request) Responder {
+	l := listRoutes("", "", r.ListRoutes(), req.Params().Bool("all"))
+	maxLenOfPath := 0
+	for _, n := range l {
+		if len(n.path) > maxLenOfPath {
+			maxLenOfPath = len(n.path)
 		}
 	}
 	b := new(strings.Builder)
+	host := ""
 	for i, n := range l {
+		if n.host != host {
+			host = n.host
+			b.WriteString("\n")
+			b.WriteString(host)
+			b.WriteString("\n")
+		}
 		format := fmt.Sprintf("%%3d. %%6s /%%-%ds %%s", maxLenOfPath)
-		line := fmt.Sprintf(format, i+1, n.Scope, n.Path(), n.HandlerPath())
+		line := fmt.Sprintf(format, i+1, n.Scope, n.path, n.HandlerPath())
 		b.WriteString(line)
+		if n.Name() != "" {
+			b.WriteString(" name=")
+			b.WriteString(n.Name())
+		}
+		if md, ok := n.Metadata().(*metadata); ok {
+			if len(md.Roles) > 0 {
+				b.WriteString(" roles=")
+				b.WriteString(strings.Join(md.Roles, "|"))
+			}
+			if len(md.Scopes) > 0 {
+				b.WriteString(" scopes=")
+				b.WriteString(strings.Join(md.Scopes, ","))
+			}
+		}
 		if n.Description() != "" {
 			b.WriteString(" #")
 			b.WriteString(n.Description())
@@ -251,20 +445,32 @@ func (r *Router) Header() *Header {
 	return r.md.Header
 }
 
+// SetCORSPolicy sets the CORS policy of endpoints bound with r afterwards, and routers created from r afterwards
+// by Group, Host or Use inherit it. Endpoints bound before are not affected. Endpoint.SetCORSPolicy overrides it
+func (r *Router) SetCORSPolicy(p *CORSPolicy) {
+	r.md.CORS = p
+}
+
+func (r *Router) CORSPolicy() *CORSPolicy {
+	return r.md.CORS
+}
+
+// SetAuthorizer sets the authorizer of endpoints bound with r afterwards, and routers created from r afterwards
+// by Group, Host or Use inherit it. Endpoints bound before are not affected
+func (r *Router) SetAuthorizer(a Authorizer) {
+	r.md.Authorizer = a
+}
+
+// SetMaxBodySize sets the max size of request body of endpoints bound with r afterwards
+func (r *Router) SetMaxBodySize(n int64) {
+	r.md.MaxBodySize = n
+}
+
 func (r *Router) toEndpoint(e *router.Endpoint) *Endpoint {
 	if e == nil {
 		return nil
 	}
-
-	new := r.md.clone()
-	if md, ok := e.Metadata().(*metadata); ok && md.Header != nil {
-		for k, v := range md.Header.Header {
-			new.Header.Header[k] = v
-		}
-	}
-	e.SetMetadata(new)
 	return &Endpoint{
 		Endpoint: e,
 	}
-
 }
@@ -0,0 +1,87 @@
+package router
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// constraint restricts values of path param, e.g. {id:int}, {slug:[a-z-]+}
+type constraint struct {
+	expr    string // E.g. int or [a-z-]+
+	re      *regexp.Regexp
+	convert func(s string) (interface{}, error)
+}
+
+var builtinConstraints = map[string]*constraint{
+	"int": {
+		re: regexp.MustCompile(`^[-+]?[0-9]+$`),
+		convert: func(s string) (interface{}, error) {
+			return strconv.ParseInt(s, 10, 64)
+		},
+	},
+	"float": {
+		re: regexp.MustCompile(`^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`),
+		convert: func(s string) (interface{}, error) {
+			return strconv.ParseFloat(s, 64)
+		},
+	},
+	"bool": {
+		re: regexp.MustCompile(`^(true|false)$`),
+		convert: func(s string) (interface{}, error) {
+			return strconv.ParseBool(s)
+		},
+	},
+	"uuid":  {re: regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)},
+	"alpha": {re: regexp.MustCompile(`^[a-zA-Z]+$`)},
+	"alnum": {re: regexp.MustCompile(`^[a-zA-Z0-9]+$`)},
+}
+
+// newConstraint returns a builtin constraint(int, float, bool, uuid, alpha or alnum), or a regular expression constraint
+// which must match the whole value
+func newConstraint(expr string) (*constraint, error) {
+	if c, ok := builtinConstraints[expr]; ok {
+		return &constraint{
+			expr:    expr,
+			re:      c.re,
+			convert: c.convert,
+		}, nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("compile constraint %s: %w", expr, err)
+	}
+	return &constraint{
+		expr: expr,
+		re:   re,
+	}, nil
+}
+
+// Match reports whether s satisfies the constraint, and returns the typed value
+func (c *constraint) Match(s string) (interface{}, bool) {
+	if !c.re.MatchString(s) {
+		return nil, false
+	}
+	if c.convert == nil {
+		return s, true
+	}
+	v, err := c.convert(s)
+	if err != nil {
+		// E.g. int overflow
+		return nil, false
+	}
+	return v, true
+}
+
+// ParseParam parses param segment into name and constraint expression, e.g. {id:int} is parsed into id and int
+func ParseParam(segment string) (name, constraint string) {
+	if !IsParam(segment) {
+		return "", ""
+	}
+	s := segment[1 : len(segment)-1]
+	if i := strings.IndexByte(s, ':'); i > 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
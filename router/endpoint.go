@@ -6,7 +6,10 @@ import (
 
 type Endpoint struct {
 	Scope string
+	// Host is the host pattern, it's empty if the endpoint matches any host
+	Host  string
 	node  *node
+	names *nameTable
 }
 
 func (e *Endpoint) Path() string {
@@ -22,6 +25,17 @@ func (e *Endpoint) Description() string {
 	return e.node.Description
 }
 
+// SetName names the endpoint in order to build its url by Router.URL. It panics if name is used by another endpoint
+func (e *Endpoint) SetName(name string) *Endpoint {
+	e.names.add(name, e.node)
+	e.node.Name = name
+	return e
+}
+
+func (e *Endpoint) Name() string {
+	return e.node.Name
+}
+
 func (e *Endpoint) HandlerPath() string {
 	return e.node.HandlerPath()
 }
@@ -39,6 +53,25 @@ func (e *Endpoint) SetModel(m interface{}) *Endpoint {
 	return e
 }
 
+// ResponseModel returns the prototype of response body with status
+func (e *Endpoint) ResponseModel(status int) interface{} {
+	return e.node.ResponseModels[status]
+}
+
+// ResponseModels returns prototypes of response body indexed by status
+func (e *Endpoint) ResponseModels() map[int]interface{} {
+	return e.node.ResponseModels
+}
+
+// SetResponseModel declares the prototype of response body with status
+func (e *Endpoint) SetResponseModel(status int, m interface{}) *Endpoint {
+	if e.node.ResponseModels == nil {
+		e.node.ResponseModels = make(map[int]interface{})
+	}
+	e.node.ResponseModels[status] = m
+	return e
+}
+
 func (e *Endpoint) Sensitive() bool {
 	return e.node.Sensitive
 }
@@ -54,3 +87,9 @@ func (e *Endpoint) Metadata() interface{} {
 func (e *Endpoint) SetMetadata(m interface{}) {
 	e.node.Metadata = m
 }
+
+// ConvertParams converts path param values into typed values according to constraints in path,
+// e.g. value of {id:int} is converted into int64
+func (e *Endpoint) ConvertParams(params map[string]string) map[string]interface{} {
+	return e.node.ConvertParams(params)
+}
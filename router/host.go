@@ -0,0 +1,114 @@
+package router
+
+import (
+	"net"
+	"strings"
+)
+
+// hostPattern matches request hosts, e.g. api.example.com or {tenant}.example.com
+type hostPattern struct {
+	pattern string
+	labels  []*hostLabel
+	// numParams decides precedence of patterns, patterns with less params are matched earlier
+	numParams int
+}
+
+type hostLabel struct {
+	static     string
+	paramName  string
+	constraint *constraint
+}
+
+func newHostPattern(pattern string) *hostPattern {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	if pattern == "" {
+		logger.Panic("host pattern is empty")
+	}
+	p := &hostPattern{pattern: pattern}
+	for _, s := range strings.Split(pattern, ".") {
+		l := new(hostLabel)
+		switch {
+		case IsParam(s):
+			var expr string
+			l.paramName, expr = ParseParam(s)
+			if expr != "" {
+				c, err := newConstraint(expr)
+				if err != nil {
+					logger.Panicf("Invalid host pattern %s: %v", pattern, err)
+				}
+				l.constraint = c
+			}
+			p.numParams++
+		case s != "" && IsStatic(s):
+			l.static = s
+		default:
+			logger.Panicf("Invalid host pattern: %s", pattern)
+		}
+		p.labels = append(p.labels, l)
+	}
+	return p
+}
+
+// Match reports whether host matches the pattern, and returns params in host
+func (p *hostPattern) Match(host string) (map[string]string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+	if len(labels) != len(p.labels) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, l := range p.labels {
+		if l.paramName == "" {
+			if l.static != labels[i] {
+				return nil, false
+			}
+			continue
+		}
+		if l.constraint != nil {
+			if _, ok := l.constraint.Match(labels[i]); !ok {
+				return nil, false
+			}
+		}
+		if params == nil {
+			params = make(map[string]string, p.numParams)
+		}
+		params[l.paramName] = labels[i]
+	}
+	return params, true
+}
+
+// hostTree is the route tree of a host pattern
+type hostTree struct {
+	pattern    *hostPattern
+	scopedRoot map[string]*node
+}
+
+// hostTable is shared by routers created from the same root router
+type hostTable struct {
+	trees []*hostTree
+}
+
+// tree returns the route tree of pattern, it's created if not exist.
+// Trees are sorted by precedence, e.g. api.example.com is matched before {tenant}.example.com
+func (t *hostTable) tree(pattern string) *hostTree {
+	p := newHostPattern(pattern)
+	for _, ht := range t.trees {
+		if ht.pattern.pattern == p.pattern {
+			return ht
+		}
+	}
+	ht := &hostTree{
+		pattern:    p,
+		scopedRoot: map[string]*node{"": NewEmptyNode()},
+	}
+	i := len(t.trees)
+	for i > 0 && t.trees[i-1].pattern.numParams > p.numParams {
+		i--
+	}
+	t.trees = append(t.trees, nil)
+	copy(t.trees[i+1:], t.trees[i:])
+	t.trees[i] = ht
+	return ht
+}
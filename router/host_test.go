@@ -0,0 +1,73 @@
+package router
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostPattern_Match(t *testing.T) {
+	p := newHostPattern("{tenant}.Example.com")
+	params, ok := p.Match("acme.example.com:8080")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"tenant": "acme"}, params)
+	_, ok = p.Match("example.com")
+	assert.False(t, ok)
+	_, ok = p.Match("a.b.example.com")
+	assert.False(t, ok)
+
+	p = newHostPattern("{id:int}.example.com")
+	_, ok = p.Match("acme.example.com")
+	assert.False(t, ok)
+	params, ok = p.Match("12.example.com")
+	assert.True(t, ok)
+	assert.Equal(t, "12", params["id"])
+}
+
+func TestRouter_MatchHost(t *testing.T) {
+	handlers := func(name string) *list.List {
+		l := list.New()
+		l.PushBack(name)
+		return l
+	}
+	r := New()
+	r.Bind("GET", "/items", handlers("default"))
+	r.Bind("GET", "/about", handlers("about"))
+	r.Host("{tenant}.example.com").Group("v1").Bind("GET", "/items", handlers("tenant"))
+	r.Host("api.example.com").Bind("GET", "/items", handlers("api"))
+
+	e, _, hp := r.MatchHost("acme.example.com", "GET", "/v1/items")
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "tenant", e.FirstHandler().Value)
+		assert.Equal(t, "{tenant}.example.com", e.Host)
+		assert.Equal(t, map[string]string{"tenant": "acme"}, hp)
+	}
+
+	e, _, hp = r.MatchHost("api.example.com", "GET", "/items")
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "api", e.FirstHandler().Value)
+		assert.Empty(t, hp)
+	}
+
+	// Falls back to routes without host
+	e, _, _ = r.MatchHost("acme.example.com", "GET", "/about")
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "about", e.FirstHandler().Value)
+		assert.Empty(t, e.Host)
+	}
+	e, _, _ = r.MatchHost("localhost", "GET", "/items")
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "default", e.FirstHandler().Value)
+	}
+	e, _, _ = r.MatchHost("localhost", "GET", "/v1/items")
+	assert.Nil(t, e)
+
+	assert.Equal(t, []string{"GET"}, r.MatchHostScopes("acme.example.com", "/v1/items"))
+
+	var hosts []string
+	for _, e := range r.ListRoutes() {
+		hosts = append(hosts, e.Host)
+	}
+	assert.Equal(t, []string{"", "", "api.example.com", "{tenant}.example.com"}, hosts)
+}
@@ -0,0 +1,103 @@
+package router
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// nameTable indexes endpoint nodes by names, it's shared by routers created from the same root router
+type nameTable struct {
+	mu    sync.RWMutex
+	nodes map[string]*node
+}
+
+func (t *nameTable) add(name string, n *node) {
+	if name == "" {
+		logger.Panic("route name is empty")
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.nodes == nil {
+		t.nodes = make(map[string]*node)
+	}
+	if prev := t.nodes[name]; prev != nil && prev != n {
+		logger.Panicf("Duplicate route name %s: /%s, /%s", name, prev.path, n.path)
+	}
+	t.nodes[name] = n
+}
+
+func (t *nameTable) get(name string) *node {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.nodes[name]
+}
+
+// buildURL builds url from path pattern of n. Values of params and wildcard are escaped,
+// and unused params are encoded into query string
+func (n *node) buildURL(params map[string]string) (string, error) {
+	used := make(map[string]bool, len(params))
+	segments := strings.Split(n.path, "/")
+	for i, s := range segments {
+		switch {
+		case IsStatic(s):
+			continue
+		case IsParam(s):
+			name, _ := ParseParam(s)
+			v, ok := params[name]
+			if !ok {
+				return "", fmt.Errorf("missing param %s", name)
+			}
+			if c := n.constraints[name]; c != nil {
+				if _, ok := c.Match(v); !ok {
+					return "", fmt.Errorf("param %s=%s doesn't satisfy constraint %s", name, v, c.expr)
+				}
+			}
+			used[name] = true
+			segments[i] = url.PathEscape(v)
+		case IsWildcard(s):
+			name := s[1:]
+			v := params[name]
+			used[name] = true
+			l := strings.Split(strings.Trim(v, "/"), "/")
+			for j, p := range l {
+				l[j] = url.PathEscape(p)
+			}
+			segments[i] = strings.Join(l, "/")
+		}
+	}
+	p := "/" + strings.TrimSuffix(strings.Join(segments, "/"), "/")
+	query := url.Values{}
+	for k, v := range params {
+		if !used[k] {
+			query.Set(k, v)
+		}
+	}
+	if len(query) > 0 {
+		p += "?" + query.Encode()
+	}
+	return p, nil
+}
+
+// URL builds url of the route named name. params are key-value pairs, e.g. URL("user.show", "id", 1, "tab", "posts").
+// Path params and wildcard are filled by params, and the others are added into query string.
+// Host of the route is not included.
+func (r *Router) URL(name string, params ...interface{}) (string, error) {
+	n := r.names.get(name)
+	if n == nil {
+		return "", fmt.Errorf("route %s not found", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("odd number of params %v", params)
+	}
+	m := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		k, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("param name %v is not string", params[i])
+		}
+		m[k] = fmt.Sprint(params[i+1])
+	}
+	return n.buildURL(m)
+}
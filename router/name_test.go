@@ -0,0 +1,45 @@
+package router
+
+import (
+	"container/list"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouter_URL(t *testing.T) {
+	handlers := func() *list.List {
+		l := list.New()
+		l.PushBack("h")
+		return l
+	}
+	r := New()
+	r.Group("users").Bind("GET", "/{id:int}", handlers()).SetName("user.show")
+	r.Bind("GET", "/files/*path", handlers()).SetName("file")
+	r.Bind("GET", "/", handlers()).SetName("home")
+
+	u, err := r.URL("user.show", "id", 12, "tab", "a b")
+	assert.NoError(t, err)
+	assert.Equal(t, "/users/12?tab=a+b", u)
+
+	_, err = r.URL("user.show", "id", "abc")
+	assert.Error(t, err)
+	_, err = r.URL("user.show")
+	assert.Error(t, err)
+	_, err = r.URL("user.list")
+	assert.Error(t, err)
+
+	u, err = r.URL("file", "path", "docs/a b.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "/files/docs/a%20b.txt", u)
+
+	u, err = r.URL("home")
+	assert.NoError(t, err)
+	assert.Equal(t, "/", u)
+
+	e, _ := r.Match("GET", "/users/1")
+	assert.Equal(t, "user.show", e.Name())
+	assert.Panics(t, func() {
+		r.Bind("GET", "/accounts/{id}", handlers()).SetName("user.show")
+	})
+}
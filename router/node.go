@@ -3,6 +3,7 @@ package router
 import (
 	"container/list"
 	"fmt"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
@@ -15,7 +16,7 @@ type nodeType int
 
 const (
 	staticNode   nodeType = iota // /users
-	paramNode                    // /users/{id}
+	paramNode                    // /users/{id} or /users/{id:int}
 	wildcardNode                 // /users/{id}/photos/*
 )
 
@@ -52,12 +53,18 @@ type node struct {
 	path      string // E.g. /items/{id}
 	segment   string // E.g. items or {id}
 	paramName string // E.g. id
-	handlers  *list.List
-	children  []*node
-
-	Model       interface{}
-	Description string
-	Sensitive   bool
+	// constraint restricts value of param, it's nil if param accepts any value
+	constraint *constraint
+	// constraints contains constraints of all params in path, which are used to convert param values
+	constraints map[string]*constraint
+	handlers    *list.List
+	children    []*node
+
+	Model          interface{}
+	ResponseModels map[int]interface{}
+	Description    string
+	Sensitive      bool
+	Name           string
 
 	Metadata interface{}
 }
@@ -66,9 +73,17 @@ func newNodeList(path string, handlers *list.List) *node {
 	path = Normalize(path)
 	segments := strings.Split(path, "/")
 	var head, p *node
+	constraints := map[string]*constraint{}
 	for i, s := range segments {
 		path := strings.Join(segments[:i+1], "/")
 		n := NewNode(path, s)
+		if n.constraint != nil {
+			constraints[n.paramName] = n.constraint
+		}
+		n.constraints = make(map[string]*constraint, len(constraints))
+		for k, v := range constraints {
+			n.constraints[k] = v
+		}
 		if p != nil {
 			p.children = []*node{n}
 		} else {
@@ -94,7 +109,15 @@ func NewNode(path, segment string) *node {
 	}
 	switch n.typ {
 	case paramNode:
-		n.paramName = segment[1 : len(segment)-1]
+		var expr string
+		n.paramName, expr = ParseParam(segment)
+		if expr != "" {
+			c, err := newConstraint(expr)
+			if err != nil {
+				logger.Panicf("Invalid segment %s: %v", segment, err)
+			}
+			n.constraint = c
+		}
 	case wildcardNode:
 		n.segment = segment[1:]
 	default:
@@ -162,6 +185,10 @@ func (n *node) Conflict(node *node) *types.Pair {
 			}
 		}
 	case paramNode:
+		// Params with different constraints don't conflict, e.g. {id:int} is matched before {name}
+		if n.constraintExpr() != node.constraintExpr() {
+			return nil
+		}
 		if n.IsEndpoint() && node.IsEndpoint() {
 			return &types.Pair{
 				First:  n,
@@ -210,33 +237,85 @@ func (n *node) Add(nod *node) {
 		return
 	}
 
-	// Mismatch: append new nodes
+	// Mismatch: insert new nodes by precedence: static > constrained param > param > wildcard
 	switch nod.typ {
 	case staticNode:
 		n.children = append([]*node{nod}, n.children...)
-	case paramNode:
+	case paramNode, wildcardNode:
 		i := len(n.children) - 1
-		for i >= 0 {
-			if n.children[i].typ != wildcardNode {
-				break
-			}
+		for i >= 0 && n.children[i].precedence() > nod.precedence() {
 			i--
 		}
+		n.children = append(n.children, nil)
+		copy(n.children[i+2:], n.children[i+1:])
+		n.children[i+1] = nod
+	default:
+		logger.Panicf("Invalid node type: %v", nod.typ)
+	}
+}
 
-		if i < 0 {
-			n.children = append([]*node{nod}, n.children...)
-		} else if i == len(n.children)-1 {
-			n.children = append(n.children, nod)
-		} else {
-			n.children = append(n.children, nod)
-			copy(n.children[i+2:], n.children[i+1:])
-			n.children[i+1] = nod
+// precedence returns matching order of node among siblings, the smaller the earlier
+func (n *node) precedence() int {
+	switch n.typ {
+	case staticNode:
+		return 0
+	case paramNode:
+		if n.constraint != nil {
+			return 1
 		}
-	case wildcardNode:
-		n.children = append(n.children, nod)
+		return 2
 	default:
-		logger.Panicf("Invalid node type: %v", nod.typ)
+		return 3
+	}
+}
+
+func (n *node) constraintExpr() string {
+	if n.constraint == nil {
+		return ""
+	}
+	return n.constraint.expr
+}
+
+// matchParam reports whether the path segment satisfies the constraint
+func (n *node) matchParam(segment string) bool {
+	if n.constraint == nil {
+		return true
+	}
+	if v, err := url.PathUnescape(segment); err == nil {
+		segment = v
+	}
+	_, ok := n.constraint.Match(segment)
+	return ok
+}
+
+// ConvertParams converts param values into typed values according to constraints, e.g. {id:int} is converted into int64
+func (n *node) ConvertParams(params map[string]string) map[string]interface{} {
+	m := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if c := n.constraints[k]; c != nil {
+			if tv, ok := c.Match(v); ok {
+				m[k] = tv
+				continue
+			}
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// FindPath finds the endpoint node whose path pattern equals path, e.g. items/{id:int}
+func (n *node) FindPath(path string) *node {
+	if n.path == path && n.IsEndpoint() {
+		return n
+	}
+	for _, child := range n.children {
+		if strings.HasPrefix(path, child.path) {
+			if match := child.FindPath(path); match != nil {
+				return match
+			}
+		}
 	}
+	return nil
 }
 
 func (n *node) MatchPath(path string) (*node, map[string]string) {
@@ -283,6 +362,9 @@ func (n *node) Match(segments ...string) (*node, map[string]string) {
 			}
 		}
 	case paramNode:
+		if !n.matchParam(first) {
+			return nil, nil
+		}
 		var match *node
 		var params map[string]string
 		if len(segments) == 1 || (segments[1] == "" && n.IsEndpoint()) {
@@ -311,6 +393,55 @@ func (n *node) Match(segments ...string) (*node, map[string]string) {
 	return nil, nil
 }
 
+// MatchFold matches segments case-insensitively, and returns the matched node and segments whose cases are fixed
+// according to static nodes, e.g. Items/12 is fixed into items/12
+func (n *node) MatchFold(segments ...string) (*node, []string) {
+	if len(segments) == 0 {
+		if n.typ == wildcardNode {
+			return n, nil
+		}
+		return nil, nil
+	}
+
+	first := segments[0]
+	switch n.typ {
+	case staticNode:
+		if !strings.EqualFold(n.segment, first) {
+			return nil, nil
+		}
+		first = n.segment
+	case paramNode:
+		if !n.matchParam(first) {
+			return nil, nil
+		}
+	case wildcardNode:
+		if n.IsEndpoint() {
+			return n, segments
+		}
+		return nil, nil
+	}
+
+	if len(segments) == 1 || (segments[1] == "" && n.IsEndpoint()) {
+		if n.IsEndpoint() {
+			return n, []string{first}
+		}
+		if n.typ == staticNode {
+			for _, child := range n.children {
+				if child.typ == wildcardNode {
+					return child, []string{first}
+				}
+			}
+		}
+		return nil, nil
+	}
+	for _, child := range n.children {
+		if match, fixed := child.MatchFold(segments[1:]...); match != nil {
+			return match, append([]string{first}, fixed...)
+		}
+	}
+	return nil, nil
+}
+
 func (n *node) HandlerPath() string {
 	reg := regexp.MustCompile(`\(\*([a-zA-Z0-9_]+)\)`)
 	s := new(strings.Builder)
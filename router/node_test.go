@@ -35,3 +35,64 @@ func TestNode_Conflict(t *testing.T) {
 	pair = root.Conflict(newNodeList("/hello/world/*", hl))
 	assert.Empty(t, pair)
 }
+
+func TestNode_Constraint(t *testing.T) {
+	root := NewEmptyNode()
+	for _, p := range []string{"/items/new", "/items/{id:int}", "/items/{slug:[a-z-]+}", "/items/{name}", "/items/*"} {
+		hl := list.New()
+		hl.PushBack(p)
+		root.Add(newNodeList(p, hl))
+	}
+	cases := map[string]string{
+		"/items/new":      "/items/new",
+		"/items/12":       "/items/{id:int}",
+		"/items/a-b":      "/items/{slug:[a-z-]+}",
+		"/items/A_1":      "/items/{name}",
+		"/items/A_1/more": "/items/*",
+	}
+	for path, expected := range cases {
+		n, _ := root.MatchPath(path)
+		if assert.NotNil(t, n, path) {
+			assert.Equal(t, expected, n.handlers.Front().Value, path)
+		}
+	}
+
+	n, params := root.MatchPath("/items/12")
+	assert.Equal(t, map[string]interface{}{"id": int64(12)}, n.ConvertParams(params))
+	// Overflowed int doesn't satisfy the constraint
+	n, _ = root.MatchPath("/items/99999999999999999999")
+	assert.Equal(t, "/items/{name}", n.handlers.Front().Value)
+
+	assert.Panics(t, func() {
+		NewNode("{id:[a-}", "{id:[a-}")
+	})
+}
+
+func TestNode_ConflictConstraint(t *testing.T) {
+	hl := list.New()
+	hl.PushBack("")
+	root := newNodeList("/items/{id:int}", hl)
+	assert.NotEmpty(t, root.Conflict(newNodeList("/items/{no:int}", hl)))
+	assert.Empty(t, root.Conflict(newNodeList("/items/{id}", hl)))
+	assert.Empty(t, root.Conflict(newNodeList("/items/{id:uuid}", hl)))
+}
+
+func TestNode_MatchFold(t *testing.T) {
+	root := NewEmptyNode()
+	for _, p := range []string{"/items/{id:int}/Detail", "/files/*"} {
+		hl := list.New()
+		hl.PushBack(p)
+		root.Add(newNodeList(p, hl))
+	}
+
+	n, fixed := root.MatchFold("", "ITEMS", "12", "detail")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, []string{"", "items", "12", "Detail"}, fixed)
+	}
+	n, fixed = root.MatchFold("", "Files", "A", "b")
+	if assert.NotNil(t, n) {
+		assert.Equal(t, []string{"", "files", "A", "b"}, fixed)
+	}
+	n, _ = root.MatchFold("", "items", "ab", "detail")
+	assert.Nil(t, n)
+}
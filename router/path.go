@@ -16,7 +16,7 @@ var (
 	compactSlashRegexp = regexp.MustCompile(`/{2,}`)
 	staticPathRegexp   = regexp.MustCompile(`^[^\\{\\}\\*]+$`)
 	wildcardPathRegexp = regexp.MustCompile(`^*[0-9a-zA-Z_\\-]*$`)
-	paramPathRegexp    = regexp.MustCompile(`^{([a-zA-Z][a-zA-Z_0-9]*|_[a-zA-Z_0-9]*[a-zA-Z0-9]+[a-zA-Z_0-9]*)}$`)
+	paramPathRegexp    = regexp.MustCompile(`^{([a-zA-Z][a-zA-Z_0-9]*|_[a-zA-Z_0-9]*[a-zA-Z0-9]+[a-zA-Z_0-9]*)(:[^/]+)?}$`)
 )
 
 func Normalize(p string) string {
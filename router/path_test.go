@@ -28,6 +28,9 @@ func TestIsParamPath(t *testing.T) {
 			"{_1}",
 			"{a1}",
 			"{a1_}",
+			"{id:int}",
+			"{slug:[a-z-]+}",
+			"{code:[0-9]{3}}",
 		}
 		for _, v := range trueCases {
 			assert.NotEmpty(t, router.IsParam(v))
@@ -42,9 +45,20 @@ func TestIsParamPath(t *testing.T) {
 			"{a",
 			"{1}",
 			"{1_a}",
+			"{id:}",
+			"{:int}",
 		}
 		for _, v := range falseCases {
 			assert.Empty(t, router.IsParam(v))
 		}
 	})
 }
+
+func TestParseParam(t *testing.T) {
+	name, c := router.ParseParam("{id}")
+	assert.Equal(t, "id", name)
+	assert.Empty(t, c)
+	name, c = router.ParseParam("{code:[0-9]{3}}")
+	assert.Equal(t, "code", name)
+	assert.Equal(t, "[0-9]{3}", c)
+}
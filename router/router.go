@@ -13,6 +13,12 @@ type Router struct {
 	scopedRoot map[string]*node
 	basePath   string
 	handlers   *list.List
+	// host is the host pattern of routes bound with r, it's empty if routes match any host
+	host string
+	// defaultRoot contains routes without host
+	defaultRoot map[string]*node
+	hosts       *hostTable
+	names       *nameTable
 }
 
 // New new a Router
@@ -20,16 +26,23 @@ func New() *Router {
 	r := &Router{
 		scopedRoot: make(map[string]*node, 4),
 		handlers:   list.New(),
+		hosts:      new(hostTable),
+		names:      new(nameTable),
 	}
 	r.scopedRoot[""] = NewEmptyNode()
+	r.defaultRoot = r.scopedRoot
 	return r
 }
 
 func (r *Router) clone() *Router {
 	nr := &Router{
-		scopedRoot: r.scopedRoot,
-		basePath:   r.basePath,
-		handlers:   list.New(),
+		scopedRoot:  r.scopedRoot,
+		basePath:    r.basePath,
+		handlers:    list.New(),
+		host:        r.host,
+		defaultRoot: r.defaultRoot,
+		hosts:       r.hosts,
+		names:       r.names,
 	}
 	nr.handlers.PushBackList(r.handlers)
 	return nr
@@ -39,6 +52,17 @@ func (r *Router) BasePath() string {
 	return r.basePath
 }
 
+// Host returns a new router whose routes only match requests to hosts matching pattern,
+// e.g. api.example.com or {tenant}.example.com. Labels can be params with constraints like path params.
+// Routes without host are matched if no route of matched hosts is found.
+func (r *Router) Host(pattern string) *Router {
+	t := r.hosts.tree(pattern)
+	nr := r.clone()
+	nr.host = t.pattern.pattern
+	nr.scopedRoot = t.scopedRoot
+	return nr
+}
+
 // Group returns a new router whose basePath is r.basePath+path
 func (r *Router) Group(path string) *Router {
 	if path == "/" {
@@ -76,13 +100,43 @@ func (r *Router) Use(handlers *list.List) *Router {
 
 // Match finds handlers and parses path parameters according to method and path
 func (r *Router) Match(scope string, path string) (*Endpoint, map[string]string) {
+	e, params := matchScopedRoot(r.scopedRoot, scope, path)
+	if e != nil {
+		e.Host = r.host
+		e.names = r.names
+	}
+	return e, params
+}
+
+// MatchHost finds handlers by host, method and path, and returns path params and host params.
+// Routes of matched host patterns are matched first, then routes without host.
+func (r *Router) MatchHost(host, scope, path string) (*Endpoint, map[string]string, map[string]string) {
+	for _, t := range r.hosts.trees {
+		hostParams, ok := t.pattern.Match(host)
+		if !ok {
+			continue
+		}
+		if e, params := matchScopedRoot(t.scopedRoot, scope, path); e != nil {
+			e.Host = t.pattern.pattern
+			e.names = r.names
+			return e, params, hostParams
+		}
+	}
+	e, params := matchScopedRoot(r.defaultRoot, scope, path)
+	if e != nil {
+		e.names = r.names
+	}
+	return e, params, nil
+}
+
+func matchScopedRoot(scopedRoot map[string]*node, scope string, path string) (*Endpoint, map[string]string) {
 	segments := strings.Split(path, "/")
 	if segments[0] != "" {
 		segments = append([]string{""}, segments...)
 	}
 
-	root := r.scopedRoot[scope]
-	global := r.scopedRoot[""]
+	root := scopedRoot[scope]
+	global := scopedRoot[""]
 	if root == nil {
 		root = global
 	}
@@ -112,6 +166,37 @@ func (r *Router) Match(scope string, path string) (*Endpoint, map[string]string)
 	}, unescaped
 }
 
+// FixPath finds the route matching host, scope and path case-insensitively, and returns the path whose cases are fixed
+// according to the route, e.g. /Items/12 is fixed into /items/12 if route /items/{id} exists
+func (r *Router) FixPath(host, scope, path string) (string, bool) {
+	for _, t := range r.hosts.trees {
+		if _, ok := t.pattern.Match(host); !ok {
+			continue
+		}
+		if p, ok := fixScopedRootPath(t.scopedRoot, scope, path); ok {
+			return p, true
+		}
+	}
+	return fixScopedRootPath(r.defaultRoot, scope, path)
+}
+
+func fixScopedRootPath(scopedRoot map[string]*node, scope string, path string) (string, bool) {
+	segments := strings.Split(path, "/")
+	if segments[0] != "" {
+		segments = append([]string{""}, segments...)
+	}
+
+	for _, root := range []*node{scopedRoot[scope], scopedRoot[""]} {
+		if root == nil {
+			continue
+		}
+		if n, fixed := root.MatchFold(segments...); n != nil {
+			return strings.Join(fixed, "/"), true
+		}
+	}
+	return "", false
+}
+
 func (r *Router) MatchScopes(path string) []string {
 	var a []string
 	for m := range r.scopedRoot {
@@ -122,6 +207,32 @@ func (r *Router) MatchScopes(path string) []string {
 	return a
 }
 
+// MatchHostScopes returns scopes which have routes matching host and path
+func (r *Router) MatchHostScopes(host, path string) []string {
+	scopes := map[string]bool{}
+	for _, t := range r.hosts.trees {
+		if _, ok := t.pattern.Match(host); !ok {
+			continue
+		}
+		for m := range t.scopedRoot {
+			if e, _ := matchScopedRoot(t.scopedRoot, m, path); e != nil {
+				scopes[m] = true
+			}
+		}
+	}
+	for m := range r.defaultRoot {
+		if e, _ := matchScopedRoot(r.defaultRoot, m, path); e != nil {
+			scopes[m] = true
+		}
+	}
+	a := make([]string, 0, len(scopes))
+	for m := range scopes {
+		a = append(a, m)
+	}
+	sort.Strings(a)
+	return a
+}
+
 // Bind binds scope, path with handlers
 func (r *Router) Bind(scope, path string, handlers *list.List) *Endpoint {
 	if path == "" {
@@ -155,10 +266,12 @@ func (r *Router) Bind(scope, path string, handlers *list.List) *Endpoint {
 		}
 		root.Add(nl)
 	}
-	n, _ := root.MatchPath(path)
+	n := root.FindPath(path)
 	return &Endpoint{
 		Scope: scope,
+		Host:  r.host,
 		node:  n,
+		names: r.names,
 	}
 }
 
@@ -173,27 +286,38 @@ func (r *Router) createRoot(scope string) *node {
 
 // Print prints all path trees
 func (r *Router) Print() {
-	for method, root := range r.scopedRoot {
-		nodes := root.ListEndpoints()
-		for _, n := range nodes {
-			logger.Debugf("%-5s %s\t%s", method, n.Path(), n.HandlerPath())
-		}
+	for _, e := range r.ListRoutes() {
+		logger.Debugf("%-5s %s/%s\t%s", e.Scope, e.Host, e.Path(), e.HandlerPath())
 	}
 }
 
+// ListRoutes returns routes of all hosts, which are sorted by host and path
 func (r *Router) ListRoutes() []*Endpoint {
 	l := make([]*Endpoint, 0, 10)
-	for scope, root := range r.scopedRoot {
+	l = r.appendRoutes(l, "", r.defaultRoot)
+	for _, t := range r.hosts.trees {
+		l = r.appendRoutes(l, t.pattern.pattern, t.scopedRoot)
+	}
+	sort.SliceStable(l, func(i, j int) bool {
+		if l[i].Host != l[j].Host {
+			return l[i].Host < l[j].Host
+		}
+		return strings.Compare(l[i].node.path, l[j].node.path) < 0
+	})
+	return l
+}
+
+func (r *Router) appendRoutes(l []*Endpoint, host string, scopedRoot map[string]*node) []*Endpoint {
+	for scope, root := range scopedRoot {
 		for _, e := range root.ListEndpoints() {
 			l = append(l, &Endpoint{
 				Scope: scope,
+				Host:  host,
 				node:  e,
+				names: r.names,
 			})
 		}
 	}
-	sort.Slice(l, func(i, j int) bool {
-		return strings.Compare(l[i].node.path, l[j].node.path) < 0
-	})
 	return l
 }
 
@@ -2,20 +2,27 @@ package wine
 
 import (
 	"context"
-	"errors"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
-	"math/rand"
+	htmltemplate "html/template"
 	"mime"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"path"
 	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/gopub/conv"
 	"github.com/gopub/environ"
+	"github.com/gopub/errors"
 	"github.com/gopub/log/v2"
 	"github.com/gopub/types"
 	"github.com/gopub/wine/ctxutil"
@@ -24,15 +31,19 @@ import (
 	"github.com/gopub/wine/internal/resource"
 	"github.com/gopub/wine/internal/respond"
 	"github.com/gopub/wine/internal/template"
+	"github.com/gopub/wine/router"
 )
 
 const (
 	faviconPath = "favicon.ico"
 
-	defaultReqMaxMem = int(8 * types.MB)
-	defaultTimeout   = 10 * time.Second
+	defaultReqMaxMem       = int(8 * types.MB)
+	defaultTimeout         = 10 * time.Second
+	defaultShutdownTimeout = 10 * time.Second
 
-	minAutoCompressionSize = 2048
+	defaultMinCompressionSize  = 2048
+	defaultMaxDecompressedSize = int(32 * types.MB)
+	defaultMaxBodySize         = int(32 * types.MB)
 )
 
 var reservedPaths = map[string]bool{
@@ -41,22 +52,67 @@ var reservedPaths = map[string]bool{
 	versionPath:  true,
 	endpointPath: true,
 	echoPath:     true,
+	openAPIPath:  true,
 	faviconPath:  true,
 }
 
+// ContractMode decides how to handle responses which don't match declared response models
+type ContractMode int
+
+const (
+	ContractOff  ContractMode = iota
+	ContractLog               // log contract drift
+	ContractFail              // log contract drift and respond 500
+)
+
+func parseContractMode(s string) ContractMode {
+	switch strings.ToLower(s) {
+	case "log":
+		return ContractLog
+	case "fail":
+		return ContractFail
+	default:
+		return ContractOff
+	}
+}
+
 type Options struct {
 	ReqFormMem      types.ByteUnit
 	Timeout         time.Duration
 	Recovery        bool
 	AutoCompression bool
+	// CompressionLevel is in range [1, 9], zero means the default level of each encoding
+	CompressionLevel int
+	// MinCompressionSize is the min size of response body to compress
+	MinCompressionSize types.ByteUnit
+	// MaxDecompressedSize is the max size of decompressed request body, zero means no limit
+	MaxDecompressedSize types.ByteUnit
+	// MaxBodySize is the max size of request body, which can be overridden by Endpoint.SetMaxBodySize. Zero means no limit.
+	// It doesn't apply to endpoints with streaming body
+	MaxBodySize     types.ByteUnit
 	LoggingReqModel bool
+	// ShutdownTimeout is the max duration to drain in-flight requests, zero means no limit
+	ShutdownTimeout time.Duration
+	// ResponseContract checks JSON responses against response models, which is supposed to be used in debug or test
+	ResponseContract ContractMode
+	// HideServerErrors hides details of 5xx errors rendered by the default ErrorRenderer
+	HideServerErrors bool
+	// RedirectTrailingSlash redirects requests whose paths end with slash to the clean paths, e.g. /items/ to /items
+	RedirectTrailingSlash bool
+	// RedirectFixedPath redirects requests to paths of routes matched case-insensitively, e.g. /Items to /items
+	RedirectFixedPath bool
 }
 
 // Server implements web server
 type Server struct {
 	*Router
 	*template.Manager
-	server *http.Server
+
+	mu            sync.Mutex
+	server        *http.Server
+	closed        chan struct{}
+	shutdownHooks []func(ctx context.Context) error
+	hijackedConns io.ConnSet
 
 	addr string
 	url  string
@@ -64,6 +120,17 @@ type Server struct {
 	Options
 	ResultLogger    func(req *Request, result *Result, cost time.Duration)
 	NotFoundHandler Handler
+	// MethodNotAllowedHandler handles requests whose paths match endpoints of other methods.
+	// Allow header is set before calling it
+	MethodNotAllowedHandler Handler
+	// ErrorRenderer renders errors returned by Error or handlers, it's ProblemRenderer by default
+	ErrorRenderer ErrorRenderer
+
+	encoders []*encoderEntry
+
+	// TLSConfig is used to serve https, e.g. set GetCertificate to provide certificates dynamically.
+	// Certificate files passed to RunTLS/ServeTLS take precedence over its Certificates.
+	TLSConfig *tls.Config
 }
 
 // NewServer returns a server
@@ -73,11 +140,20 @@ func NewServer(options *Options) *Server {
 
 	if options == nil {
 		options = &Options{
-			ReqFormMem:      types.ByteUnit(environ.SizeInBytes("wine.max_memory", defaultReqMaxMem)),
-			Timeout:         environ.Duration("wine.timeout", defaultTimeout),
-			Recovery:        environ.Bool("wine.recovery", true),
-			AutoCompression: environ.Bool("wine.compression.auto", true),
-			LoggingReqModel: environ.Bool("wine.logging.request.model", true),
+			ReqFormMem:            types.ByteUnit(environ.SizeInBytes("wine.max_memory", defaultReqMaxMem)),
+			Timeout:               environ.Duration("wine.timeout", defaultTimeout),
+			Recovery:              environ.Bool("wine.recovery", true),
+			AutoCompression:       environ.Bool("wine.compression.auto", true),
+			CompressionLevel:      environ.Int("wine.compression.level", io.DefaultCompressionLevel),
+			MinCompressionSize:    types.ByteUnit(environ.SizeInBytes("wine.compression.min_size", defaultMinCompressionSize)),
+			MaxDecompressedSize:   types.ByteUnit(environ.SizeInBytes("wine.decompression.max_size", defaultMaxDecompressedSize)),
+			MaxBodySize:           types.ByteUnit(environ.SizeInBytes("wine.max_body_size", defaultMaxBodySize)),
+			LoggingReqModel:       environ.Bool("wine.logging.request.model", true),
+			ShutdownTimeout:       environ.Duration("wine.shutdown_timeout", defaultShutdownTimeout),
+			ResponseContract:      parseContractMode(environ.String("wine.response.contract", "off")),
+			HideServerErrors:      environ.Bool("wine.error.hide_server_errors", false),
+			RedirectTrailingSlash: environ.Bool("wine.redirect.trailing_slash", false),
+			RedirectFixedPath:     environ.Bool("wine.redirect.fixed_path", false),
 		}
 	}
 
@@ -86,17 +162,24 @@ func NewServer(options *Options) *Server {
 		Manager:      template.NewManager(),
 		ResultLogger: logResult,
 		Options:      *options,
+		encoders:     defaultEncoders(),
 	}
 
 	s.AddTemplateFuncMap(template.FuncMap)
+	// E.g. {{url "user.show" "id" .ID}}
+	s.AddTemplateFuncMap(htmltemplate.FuncMap{"url": s.Router.URL})
 	return s
 }
 
 func (s *Server) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.addr
 }
 
 func (s *Server) URL() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	return s.url
 }
 
@@ -119,49 +202,195 @@ func (s *Server) assignAddr(addr string, tls bool) {
 	}
 }
 
-// Run starts server
+// Run starts server and blocks until it's terminated by SIGINT or SIGTERM, then shuts down gracefully
 func (s *Server) Run(addr string) {
-	if s.server != nil {
-		logger.Panicf("HTTP Server is running")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	l, err := listen(addr, ":http")
+	if err != nil {
+		logger.Panicf("HTTP server failed to start: %v", err)
 	}
+	logger.Infof("HTTP server is running on %s", l.Addr())
+	if err := s.Serve(ctx, l); err != nil {
+		logger.Panicf("HTTP server was terminated: %v", err)
+	}
+	logger.Infof("HTTP server was closed")
+}
 
-	logger.Infof("HTTP server is running on %s", addr)
-	s.server = &http.Server{Addr: addr, Handler: s}
-	s.assignAddr(s.server.Addr, false)
-	err := s.server.ListenAndServe()
-	s.assignAddr("", false)
+// RunTLS starts server with tls and blocks until it's terminated by SIGINT or SIGTERM, then shuts down gracefully
+func (s *Server) RunTLS(addr, certFile, keyFile string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	l, err := listen(addr, ":https")
 	if err != nil {
+		logger.Panicf("HTTPS server failed to start: %v", err)
+	}
+	logger.Infof("HTTPS server is running on %s", l.Addr())
+	if err := s.ServeTLS(ctx, l, certFile, keyFile); err != nil {
+		logger.Panicf("HTTPS server was terminated: %v", err)
+	}
+	logger.Infof("HTTPS server was closed")
+}
+
+// ListenAndServe listens on addr and serves until ctx is done or Shutdown is called
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	l, err := listen(addr, ":http")
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// ListenAndServeTLS listens on addr and serves https until ctx is done or Shutdown is called
+func (s *Server) ListenAndServeTLS(ctx context.Context, addr, certFile, keyFile string) error {
+	l, err := listen(addr, ":https")
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(ctx, l, certFile, keyFile)
+}
+
+// listen listens on tcp addr, defaultAddr is used if addr is empty
+func listen(addr, defaultAddr string) (net.Listener, error) {
+	if addr == "" {
+		addr = defaultAddr
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen: %w", err)
+	}
+	return l, nil
+}
+
+// Serve accepts connections on l until ctx is done or Shutdown is called.
+// Once ctx is done, the server will be shut down gracefully.
+// It returns nil if the server is closed normally.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	return s.serveListener(ctx, l, nil, func(srv *http.Server) error {
+		return srv.Serve(l)
+	})
+}
+
+// ServeTLS is similar with Serve, but serves https.
+// Certificate is loaded from certFile and keyFile, and reloaded once they're changed.
+// certFile and keyFile can be empty if TLSConfig provides certificates.
+func (s *Server) ServeTLS(ctx context.Context, l net.Listener, certFile, keyFile string) error {
+	cfg := s.TLSConfig.Clone()
+	if cfg == nil {
+		cfg = new(tls.Config)
+	}
+	if certFile != "" || keyFile != "" {
+		r, err := NewCertificateReloader(certFile, keyFile)
+		if err != nil {
+			l.Close()
+			return fmt.Errorf("load certificate: %w", err)
+		}
+		cfg.Certificates = nil
+		cfg.GetCertificate = r.GetCertificate
+	}
+	return s.serveListener(ctx, l, cfg, func(srv *http.Server) error {
+		return srv.ServeTLS(l, "", "")
+	})
+}
+
+func (s *Server) serveListener(ctx context.Context, l net.Listener, cfg *tls.Config, serve func(srv *http.Server) error) error {
+	srv, closed, err := s.start(l, cfg)
+	if err != nil {
+		l.Close()
+		return err
+	}
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- serve(srv)
+	}()
+
+	select {
+	case err = <-errC:
 		if errors.Is(err, http.ErrServerClosed) {
-			logger.Infof("HTTP server was closed")
-		} else {
-			logger.Panicf("HTTP server was terminated: %v", err)
+			// Wait for draining in-flight requests and running hooks
+			<-closed
+			return nil
 		}
+		// Serving failed, e.g. invalid tls certificate
+		s.ShutdownContext(context.Background())
+		return err
+	case <-ctx.Done():
+		err = s.Shutdown()
+		<-errC
+		return err
 	}
 }
 
-// RunTLS starts server with tls
-func (s *Server) RunTLS(addr, certFile, keyFile string) {
+func (s *Server) start(l net.Listener, cfg *tls.Config) (*http.Server, <-chan struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.server != nil {
-		logger.Panic("HTTPS Server is running")
+		return nil, nil, errors.New("server is running")
 	}
+	s.server = &http.Server{Handler: s, TLSConfig: cfg}
+	s.closed = make(chan struct{})
+	s.assignAddr(l.Addr().String(), cfg != nil)
+	return s.server, s.closed, nil
+}
+
+// OnShutdown registers a function to call on Shutdown, after in-flight requests are drained and
+// hijacked connections are closed. It can be used to flush logs or close session providers, etc.
+func (s *Server) OnShutdown(f func(ctx context.Context) error) {
+	s.mu.Lock()
+	s.shutdownHooks = append(s.shutdownHooks, f)
+	s.mu.Unlock()
+}
+
+// Shutdown shuts down server gracefully, waiting at most ShutdownTimeout for in-flight requests
+func (s *Server) Shutdown() error {
+	ctx := context.Background()
+	if s.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.ShutdownTimeout)
+		defer cancel()
+	}
+	return s.ShutdownContext(ctx)
+}
 
-	logger.Infof("HTTPS server is running on %s", addr)
-	s.server = &http.Server{Addr: addr, Handler: s}
-	s.assignAddr(s.server.Addr, true)
-	err := s.server.ListenAndServeTLS(certFile, keyFile)
+// ShutdownContext stops accepting new connections and drains in-flight requests until ctx is done.
+// Remaining connections, including hijacked ones(e.g. websocket), will be closed forcibly,
+// then hooks registered by OnShutdown are called.
+func (s *Server) ShutdownContext(ctx context.Context) error {
+	s.mu.Lock()
+	srv, closed, hooks := s.server, s.closed, s.shutdownHooks
+	s.server, s.closed = nil, nil
 	s.assignAddr("", false)
+	s.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+
+	err := srv.Shutdown(ctx)
 	if err != nil {
-		if errors.Is(err, http.ErrServerClosed) {
-			logger.Infof("HTTPS server was closed")
-		} else {
-			logger.Panicf("HTTPS server was terminated: %v", err)
+		logger.Errorf("Cannot drain connections: %v", err)
+		if er := srv.Close(); er != nil {
+			logger.Errorf("Close: %v", er)
 		}
 	}
-}
 
-// Shutdown shutdown server
-func (s *Server) Shutdown() error {
-	return s.server.Shutdown(context.Background())
+	if n := s.hijackedConns.Len(); n > 0 {
+		logger.Infof("Closing %d hijacked connections", n)
+		if er := s.hijackedConns.CloseAll(); er != nil {
+			logger.Errorf("Close hijacked connections: %v", er)
+		}
+	}
+
+	for _, h := range hooks {
+		if er := h(ctx); er != nil {
+			logger.Errorf("Shutdown hook: %v", er)
+			if err == nil {
+				err = er
+			}
+		}
+	}
+	close(closed)
+	return err
 }
 
 func (s *Server) Match(scope string, path string) (*Endpoint, map[string]string) {
@@ -169,6 +398,39 @@ func (s *Server) Match(scope string, path string) (*Endpoint, map[string]string)
 	return s.toEndpoint(e), p
 }
 
+// MatchHost finds the endpoint by host, scope and path, and returns path params and host params.
+// Endpoints bound with Host are matched before endpoints without host.
+// HEAD requests are served by GET endpoints if there are no HEAD endpoints.
+func (s *Server) MatchHost(host, scope, path string) (*Endpoint, map[string]string, map[string]string) {
+	e, p, hp := s.Router.MatchHost(host, scope, path)
+	if e == nil && scope == http.MethodHead {
+		e, p, hp = s.Router.MatchHost(host, http.MethodGet, path)
+	}
+	return s.toEndpoint(e), p, hp
+}
+
+// allowedMethods returns methods of endpoints matching host and path, including HEAD if GET is allowed and OPTIONS.
+// It returns nil if no endpoint matches
+func (s *Server) allowedMethods(host, path string) []string {
+	var methods []string
+	for _, m := range s.MatchHostScopes(host, path) {
+		if m == "" {
+			// Bound with any method
+			continue
+		}
+		methods = append(methods, m)
+		if m == http.MethodGet {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	methods = uniqueStrings(append(methods, http.MethodOptions))
+	sort.Strings(methods)
+	return methods
+}
+
 // ServeHTTP implements for http.Handler interface, which will handle each http request
 func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	startAt := time.Now()
@@ -184,33 +446,63 @@ func (s *Server) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	ctx, cancel := s.initContext(req)
 	defer cancel()
 
-	wReq, err := parseRequest(req, s.ReqFormMem)
+	endpoint, params, hostParams := s.MatchHost(req.Host, req.Method, router.Normalize(req.URL.Path))
+	maxBodySize := int64(s.MaxBodySize)
+	streaming := false
+	if endpoint != nil {
+		streaming = endpoint.StreamingBody()
+		if streaming {
+			// Streaming bodies are only limited by the endpoint's own max body size, e.g. uploading large files
+			maxBodySize = 0
+		}
+		if endpoint.MaxBodySize() != 0 {
+			maxBodySize = endpoint.MaxBodySize()
+		}
+	}
+	wReq, err := parseRequest(rw, req, maxBodySize, streaming, &s.Options)
 	if err != nil {
-		defer s.closeWriter(rw)
-		resp := Text(http.StatusBadRequest, fmt.Sprintf("Parse request: %v", err))
-		resp.Respond(ctx, rw)
-		s.logResult(&Request{request: req}, rw, startAt)
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, io.ErrUnsupportedEncoding):
+			status = http.StatusUnsupportedMediaType
+		case errors.Is(err, io.ErrBodyTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		}
+		wReq = &Request{request: req, groupedParams: &GroupedParams{}}
+		s.renderError(ctx, wReq, errors.Format(status, "Parse request: %v", err)).Respond(ctx, rw)
+		s.logResult(wReq, rw, startAt)
 		return
 	}
-	s.serve(ctx, wReq, rw)
+	s.serve(ctx, wReq, endpoint, hostParams, params, rw)
 	s.logResult(wReq, rw, startAt)
 }
 
-func (s *Server) serve(ctx context.Context, req *Request, rw http.ResponseWriter) {
+func (s *Server) serve(ctx context.Context, req *Request, endpoint *Endpoint, hostParams, params map[string]string, rw http.ResponseWriter) {
 	np := req.NormalizedPath()
 	method := req.Request().Method
-	endpoint, params := s.Match(method, np)
-	req.setPathParams(params)
 	req.endpoint = endpoint
+	ctx = context.WithValue(ctx, ctxutil.KeyErrorRenderer, func(ctx context.Context, err error) Responder {
+		return s.renderError(ctx, req, err)
+	})
+	req.setHostParams(hostParams)
+	req.setPathParams(params)
 	s.Header().WriteTo(rw)
 	var h Handler
 	switch {
+	case s.preflightPolicy(req) != nil:
+		h = HandlerFunc(s.handlePreflight)
+	case endpoint != nil && s.RedirectTrailingSlash && len(req.request.URL.Path) > 1 && strings.HasSuffix(req.request.URL.Path, "/"):
+		h = HandleResponder(redirectPath(req, "/"+np))
 	case endpoint != nil:
 		endpoint.Header().WriteTo(rw)
+		if !checkCORS(endpoint.CORSPolicy(), req.request, rw) {
+			Text(http.StatusForbidden, "CORS: origin %s is not allowed", req.Header(httpvalue.Origin)).Respond(ctx, rw)
+			return
+		}
 		req.sensitive = endpoint.Sensitive()
 		if m := endpoint.Model(); m != nil {
 			if err := req.bind(m); err != nil {
-				Error(err).Respond(ctx, rw)
+				s.renderError(ctx, req, NewProblem(req, err, http.StatusBadRequest)).Respond(ctx, rw)
 				return
 			}
 			if s.LoggingReqModel && !endpoint.Sensitive() {
@@ -229,46 +521,56 @@ func (s *Server) serve(ctx context.Context, req *Request, rw http.ResponseWriter
 	case np == faviconPath:
 		h = HandleResponder(respond.Bytes(http.StatusOK, resource.Favicon))
 	default:
-		if s.NotFoundHandler != nil {
-			h = s.NotFoundHandler
-		} else {
-			h = HandleResponder(Status(http.StatusNotFound))
-		}
+		h = s.noEndpointHandler(req, rw)
 	}
 
-	resp := h.HandleRequest(ctx, req)
-	if resp == nil {
-		resp = Status(http.StatusNotImplemented)
+	resp := s.resolveResponder(ctx, req, h.HandleRequest(ctx, req))
+	if endpoint != nil && s.ResponseContract != ContractOff {
+		if err := checkResponseContract(endpoint, resp); err != nil {
+			log.FromContext(ctx).Errorf("Contract drift %s %s: %v", method, np, err)
+			if s.ResponseContract == ContractFail {
+				resp = Text(http.StatusInternalServerError, "Contract drift: %v", err)
+			}
+		}
 	}
-	rw = s.compressWriter(rw, req, resp)
+	rw = s.compressWriter(rw, req)
 	defer s.closeWriter(rw)
 	resp.Respond(ctx, rw)
 }
 
-func (s *Server) compressWriter(w http.ResponseWriter, req *Request, responder Responder) http.ResponseWriter {
-	if !s.AutoCompression {
-		return w
-	}
-
-	respObj, ok := responder.(*respond.Response)
-	if !ok {
-		return w
-	}
-
-	if !httpvalue.IsMIMETextType(httpvalue.GetContentType(respObj.Header())) {
-		return w
+// resolveResponder renders errors and applies server's settings to responders returned by handlers
+func (s *Server) resolveResponder(ctx context.Context, req *Request, resp Responder) Responder {
+	switch r := resp.(type) {
+	case nil:
+		return Status(http.StatusNotImplemented)
+	case *errorResponder:
+		return s.renderError(ctx, req, r.err)
+	case *errors.Error:
+		return s.renderError(ctx, req, r)
+	case *Negotiation:
+		n := *r
+		n.encoders = s.encoders
+		return &n
+	case *headerResponder:
+		return &headerResponder{Responder: s.resolveResponder(ctx, req, r.Responder), header: r.header}
+	default:
+		return resp
 	}
+}
 
-	if respObj.ContentLength() < minAutoCompressionSize {
+// compressWriter creates a writer which compresses the response with the encoding accepted by client.
+// Whether to compress is decided when the response is written, as streaming responses' content type and size are unknown in advance.
+func (s *Server) compressWriter(w http.ResponseWriter, req *Request) http.ResponseWriter {
+	if !s.AutoCompression {
 		return w
 	}
-
-	cw, err := CompressWriter(w, httpvalue.GetAcceptEncodings(req.request.Header)...)
-	if err != nil {
-		logger.Errorf("Cannot create compress writer: %w", err)
+	rw, ok := w.(*io.ResponseWriter)
+	if !ok {
 		return w
 	}
-	return cw
+	// Respond without compression even if identity is unacceptable
+	encoding := httpvalue.NegotiateEncoding(req.request.Header, io.Encodings)
+	return io.NewAutoCompressResponseWriter(rw, encoding, s.CompressionLevel, int(s.MinCompressionSize))
 }
 
 func (s *Server) wrapResponseWriter(rw http.ResponseWriter, req *http.Request) http.ResponseWriter {
@@ -279,6 +581,7 @@ func (s *Server) wrapResponseWriter(rw http.ResponseWriter, req *http.Request) h
 	}
 	s.Router.md.Header.WriteTo(rw)
 	w := io.NewResponseWriter(rw)
+	w.TrackHijackedConns(&s.hijackedConns)
 	return w
 }
 
@@ -290,7 +593,7 @@ func (s *Server) initContext(req *http.Request) (context.Context, context.Cancel
 }
 
 func (s *Server) closeWriter(w http.ResponseWriter) {
-	if cw, ok := w.(*io.CompressResponseWriter); ok {
+	if cw, ok := w.(interface{ Close() error }); ok {
 		err := cw.Close()
 		if err != nil {
 			logger.Errorf("Close compressed response writer: %v", err)
@@ -298,12 +601,53 @@ func (s *Server) closeWriter(w http.ResponseWriter) {
 	}
 }
 
-func (s *Server) handleOptions(_ context.Context, req *Request) Responder {
-	// TODO: how to handle preflight correctly?
-	methods := s.MatchScopes(req.NormalizedPath())
-	if len(methods) > 0 {
-		methods = append(methods, http.MethodOptions)
+// noEndpointHandler returns the handler of requests which match no endpoint.
+// It responds 405 if the path matches endpoints of other methods, otherwise redirects to the fixed path or responds 404
+func (s *Server) noEndpointHandler(req *Request, rw http.ResponseWriter) Handler {
+	host := req.request.Host
+	np := req.NormalizedPath()
+	if methods := s.allowedMethods(host, np); len(methods) > 0 {
+		rw.Header().Set(httpvalue.Allow, strings.Join(methods, ", "))
+		if s.MethodNotAllowedHandler != nil {
+			return s.MethodNotAllowedHandler
+		}
+		return HandleResponder(Status(http.StatusMethodNotAllowed))
+	}
+	if s.RedirectFixedPath {
+		method := req.request.Method
+		p, ok := s.FixPath(host, method, np)
+		if !ok && method == http.MethodHead {
+			p, ok = s.FixPath(host, http.MethodGet, np)
+		}
+		if ok {
+			return HandleResponder(redirectPath(req, p))
+		}
+	}
+	if s.NotFoundHandler != nil {
+		return s.NotFoundHandler
+	}
+	return HandleResponder(Status(http.StatusNotFound))
+}
+
+// redirectPath redirects req to path permanently with query kept.
+// 308 is used for methods other than GET and HEAD in order to keep methods and bodies.
+// Prefix stripped by Router.Mount is prepended to path if the server is mounted
+func redirectPath(req *Request, path string) Responder {
+	u := *req.request.URL
+	u.Path = ctxutil.GetMountPrefix(req.request.Context()) + path
+	u.RawPath = ""
+	status := http.StatusMovedPermanently
+	if m := req.request.Method; m != http.MethodGet && m != http.MethodHead {
+		status = http.StatusPermanentRedirect
 	}
+	return respond.Func(func(ctx context.Context, rw http.ResponseWriter) {
+		http.Redirect(rw, req.request, u.RequestURI(), status)
+	})
+}
+
+// handleOptions responds to OPTIONS requests which are not preflight requests of endpoints with CORS policy
+func (s *Server) handleOptions(_ context.Context, req *Request) Responder {
+	methods := s.allowedMethods(req.request.Host, req.NormalizedPath())
 	return respond.Func(func(ctx context.Context, rw http.ResponseWriter) {
 		rw.Header().Set(httpvalue.ACLAllowMethods, strings.Join(methods, ","))
 		rw.WriteHeader(http.StatusNoContent)
@@ -374,6 +718,9 @@ func logResult(req *Request, res *Result, cost time.Duration) {
 type TestServer struct {
 	*Server
 	URL string
+
+	t       *testing.T
+	rootCAs *x509.CertPool
 }
 
 func NewTestServer(t *testing.T) *TestServer {
@@ -383,25 +730,59 @@ func NewTestServer(t *testing.T) *TestServer {
 	})
 	return &TestServer{
 		Server: s,
+		t:      t,
 	}
 }
 
+// Run starts serving on a random local port and returns the base url
 func (s *TestServer) Run() string {
-	for n := 0; n < 10; n++ {
-		addr := fmt.Sprintf("localhost:%d", rand.Int()%1e4+1e4)
-		conn, err := net.Dial("TCP", addr)
-		if err != nil {
-			go s.Server.Run(addr)
-			s.URL = "http://" + addr
-			break
-		}
-		conn.Close()
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		s.t.Fatalf("Cannot listen: %v", err)
 	}
+	s.URL = "http://" + l.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+	go func() {
+		if err := s.Serve(ctx, l); err != nil {
+			logger.Errorf("Serve: %v", err)
+		}
+	}()
 	return s.URL
 }
 
+// RunTLS starts serving https on a random local port and returns the base url.
+// The certificate is issued by an in-memory self-signed CA, which is trusted by Client.
 func (s *TestServer) RunTLS() string {
-	// TODO:
-	logger.Panic("Not implemented")
+	cert, pool, err := newTestCertificate()
+	if err != nil {
+		s.t.Fatalf("Cannot create certificate: %v", err)
+	}
+	s.rootCAs = pool
+	s.TLSConfig = &tls.Config{Certificates: []tls.Certificate{*cert}}
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		s.t.Fatalf("Cannot listen: %v", err)
+	}
+	s.URL = "https://" + l.Addr().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	s.t.Cleanup(cancel)
+	go func() {
+		if err := s.ServeTLS(ctx, l, "", ""); err != nil {
+			logger.Errorf("ServeTLS: %v", err)
+		}
+	}()
 	return s.URL
 }
+
+// Client returns a client which trusts the test server's certificate
+func (s *TestServer) Client() *Client {
+	if s.rootCAs == nil {
+		return NewClient(new(http.Client))
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = &tls.Config{RootCAs: s.rootCAs}
+	c := NewClient(&http.Client{Transport: tr})
+	s.t.Cleanup(tr.CloseIdleConnections)
+	return c
+}
@@ -3,16 +3,20 @@ package wine_test
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"math/rand"
+	"mime/multipart"
 	"net/http"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gopub/errors"
 	"github.com/gopub/wine"
+	"github.com/gopub/wine/ctxutil"
 	"github.com/gopub/wine/httpvalue"
 	"github.com/stretchr/testify/require"
 )
@@ -93,12 +97,13 @@ func TestServerMethod(t *testing.T) {
 		require.Equal(t, "PUT", string(body))
 	})
 
-	t.Run("NotFound", func(t *testing.T) {
+	t.Run("MethodNotAllowed", func(t *testing.T) {
 		req, err := http.NewRequest(http.MethodDelete, url, nil)
 		require.NoError(t, err)
 		resp, err := http.DefaultClient.Do(req)
 		require.NoError(t, err)
-		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		require.Equal(t, "GET, HEAD, OPTIONS, POST, PUT", resp.Header.Get("Allow"))
 	})
 }
 
@@ -236,3 +241,401 @@ func TestServer_Bind(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+func TestServer_Shutdown(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.ShutdownTimeout = time.Second
+	started := make(chan struct{})
+	server.Get("/slow", func(ctx context.Context, req *wine.Request) wine.Responder {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		return wine.Text(http.StatusOK, "done")
+	})
+	var hookCalled bool
+	server.OnShutdown(func(ctx context.Context) error {
+		hookCalled = true
+		return nil
+	})
+	url := server.Run()
+
+	respC := make(chan *http.Response, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			t.Error(err)
+		}
+		respC <- resp
+	}()
+	<-started
+	require.NoError(t, server.Shutdown())
+	require.True(t, hookCalled)
+	resp := <-respC
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	require.NoError(t, err)
+	require.Equal(t, "done", string(body))
+
+	_, err = http.Get(url + "/slow")
+	require.Error(t, err)
+}
+
+func TestTestServer_RunTLS(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.Get("/hello", func(ctx context.Context, req *wine.Request) wine.Responder {
+		if req.Request().TLS == nil {
+			return errors.BadRequest("expected tls")
+		}
+		return wine.JSON(http.StatusOK, "world")
+	})
+	url := server.RunTLS()
+	require.True(t, strings.HasPrefix(url, "https://"))
+
+	var res string
+	err := server.Client().Get(context.Background(), url+"/hello", nil, &res)
+	require.NoError(t, err)
+	require.Equal(t, "world", res)
+
+	_, err = http.Get(url + "/hello")
+	require.Error(t, err)
+}
+
+func TestServer_MaxBodySize(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.MaxBodySize = 100
+	handler := func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, "%d", len(req.Body()))
+	}
+	server.Post("/small", handler)
+	server.Post("/upload", handler).SetMaxBodySize(1000)
+	server.Post("/unlimited", handler).SetMaxBodySize(-1)
+	url := server.Run()
+
+	post := func(t *testing.T, path string, size int, chunked bool) *http.Response {
+		var body io.Reader = strings.NewReader(strings.Repeat("a", size))
+		if chunked {
+			// Unknown content length
+			body = io.MultiReader(body)
+		}
+		req, err := http.NewRequest(http.MethodPost, url+path, body)
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Accept", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	require.Equal(t, http.StatusOK, post(t, "/small", 100, false).StatusCode)
+	resp := post(t, "/small", 101, false)
+	require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	require.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+	require.Equal(t, http.StatusRequestEntityTooLarge, post(t, "/small", 101, true).StatusCode)
+	require.Equal(t, http.StatusOK, post(t, "/upload", 1000, true).StatusCode)
+	require.Equal(t, http.StatusRequestEntityTooLarge, post(t, "/upload", 1001, true).StatusCode)
+	require.Equal(t, http.StatusOK, post(t, "/unlimited", 10000, false).StatusCode)
+}
+
+func TestServer_StreamingBody(t *testing.T) {
+	server := wine.NewTestServer(t)
+	// Server's default limit doesn't apply to streaming bodies
+	server.MaxBodySize = 1024
+	server.Post("/raw", func(ctx context.Context, req *wine.Request) wine.Responder {
+		require.Nil(t, req.Body())
+		b, err := ioutil.ReadAll(req.BodyReader())
+		if err != nil {
+			return wine.Error(err)
+		}
+		return wine.Text(http.StatusOK, "%s %d %s", req.Params().String("id"), len(b), string(b[:3]))
+	}).SetStreamingBody(true)
+	server.Post("/parts", func(ctx context.Context, req *wine.Request) wine.Responder {
+		mr, err := req.MultipartReader()
+		if err != nil {
+			return wine.Error(err)
+		}
+		var names []string
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return wine.Error(err)
+			}
+			b, err := ioutil.ReadAll(p)
+			if err != nil {
+				return wine.Error(err)
+			}
+			names = append(names, fmt.Sprintf("%s:%s", p.FormName(), string(b)))
+		}
+		return wine.Text(http.StatusOK, strings.Join(names, ","))
+	}).SetStreamingBody(true).SetMaxBodySize(-1)
+	server.Post("/limited", func(ctx context.Context, req *wine.Request) wine.Responder {
+		_, err := ioutil.ReadAll(req.BodyReader())
+		if err != nil {
+			return wine.Error(err)
+		}
+		return wine.Text(http.StatusOK, "ok")
+	}).SetStreamingBody(true).SetMaxBodySize(2048)
+	server.Post("/buffered", func(ctx context.Context, req *wine.Request) wine.Responder {
+		_, err := req.MultipartReader()
+		require.Error(t, err)
+		return wine.Text(http.StatusOK, "%d", len(req.Body()))
+	})
+	url := server.Run()
+
+	post := func(t *testing.T, path, contentType string, body io.Reader) string {
+		resp, err := http.Post(url+path, contentType, body)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	t.Run("Raw", func(t *testing.T) {
+		body := strings.Repeat("abc", 1<<20)
+		require.Equal(t, "1 3145728 abc", post(t, "/raw?id=1", "application/json", strings.NewReader(body)))
+	})
+
+	t.Run("Multipart", func(t *testing.T) {
+		body := new(strings.Builder)
+		mw := multipart.NewWriter(body)
+		require.NoError(t, mw.WriteField("title", "hello"))
+		fw, err := mw.CreateFormFile("file", "a.txt")
+		require.NoError(t, err)
+		_, err = io.WriteString(fw, "content")
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+		require.Equal(t, "title:hello,file:content", post(t, "/parts", mw.FormDataContentType(), strings.NewReader(body.String())))
+	})
+
+	t.Run("Buffered", func(t *testing.T) {
+		require.Equal(t, "5", post(t, "/buffered", "text/plain", strings.NewReader("hello")))
+	})
+
+	t.Run("Limited", func(t *testing.T) {
+		require.Equal(t, "ok", post(t, "/limited", "text/plain", strings.NewReader(strings.Repeat("a", 2048))))
+		resp, err := http.Post(url+"/limited", "text/plain", strings.NewReader(strings.Repeat("a", 2049)))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	})
+
+	t.Run("LimitedChunked", func(t *testing.T) {
+		// Content length is unknown, the limit is exceeded while reading
+		post := func(path, contentType string, body string) int {
+			req, err := http.NewRequest(http.MethodPost, url+path, ioutil.NopCloser(strings.NewReader(body)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", contentType)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		require.Equal(t, http.StatusRequestEntityTooLarge, post("/limited", "text/plain", strings.Repeat("a", 2049)))
+		require.Equal(t, http.StatusOK, post("/limited", "text/plain", strings.Repeat("a", 2048)))
+	})
+}
+
+func TestServer_PathParamConstraint(t *testing.T) {
+	server := wine.NewTestServer(t)
+	r := server.Router
+	r.Get("/items/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, fmt.Sprintf("id %T %v", req.GroupedParams().PathParams["id"], req.Params().Int64("id")))
+	})
+	r.Get("/items/{uid:uuid}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, "uuid "+req.Params().String("uid"))
+	})
+	r.Get("/items/{slug}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, "slug "+req.Params().String("slug"))
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, path string) string {
+		resp, err := http.Get(url + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+	require.Equal(t, "id int64 12", get(t, "/items/12"))
+	id := uuid.NewString()
+	require.Equal(t, "uuid "+id, get(t, "/items/"+id))
+	require.Equal(t, "slug abc", get(t, "/items/abc"))
+
+	t.Run("OpenAPI", func(t *testing.T) {
+		doc := server.OpenAPI()
+		item := doc.Paths["/items/{id}"]
+		require.NotNil(t, item)
+		require.Equal(t, "integer", item.Get.Parameters[0].Schema.Type)
+		require.Equal(t, "getItemsById", item.Get.OperationID)
+	})
+}
+
+func TestServer_Host(t *testing.T) {
+	server := wine.NewTestServer(t)
+	r := server.Router
+	r.Host("{tenant}.example.com").Get("/items/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, fmt.Sprintf("%s %d", req.Params().String("tenant"), req.Params().Int64("id")))
+	})
+	r.Get("/items/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, fmt.Sprint(req.Params().Int64("id")))
+	})
+	url := server.Run()
+
+	get := func(t *testing.T, host, path string) string {
+		req, err := http.NewRequest(http.MethodGet, url+path, nil)
+		require.NoError(t, err)
+		req.Host = host
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+	require.Equal(t, "acme 12", get(t, "acme.example.com", "/items/12"))
+	require.Equal(t, "12", get(t, "localhost", "/items/12"))
+	require.Contains(t, get(t, "localhost", "/_wine/endpoints"), "{tenant}.example.com\n")
+}
+
+func TestServer_MethodNotAllowed(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.RedirectTrailingSlash = true
+	server.RedirectFixedPath = true
+	r := server.Router
+	r.Get("/items/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, fmt.Sprint(req.Params().Int64("id")))
+	})
+	r.Put("/items/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	})
+	url := server.Run()
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	do := func(t *testing.T, method, path string) *http.Response {
+		req, err := http.NewRequest(method, url+path, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	t.Run("405", func(t *testing.T) {
+		resp := do(t, http.MethodDelete, "/items/1")
+		require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+		require.Equal(t, "GET, HEAD, OPTIONS, PUT", resp.Header.Get("Allow"))
+		require.Equal(t, http.StatusNotFound, do(t, http.MethodDelete, "/items/abc").StatusCode)
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		resp := do(t, http.MethodHead, "/items/1")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		resp := do(t, http.MethodGet, "/items/1/?a=b")
+		require.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		require.Equal(t, "/items/1?a=b", resp.Header.Get("Location"))
+
+		resp = do(t, http.MethodPut, "/Items/1")
+		require.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+		require.Equal(t, "/items/1", resp.Header.Get("Location"))
+	})
+}
+
+func TestServer_NamedRoute(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.AddTextTemplate("link", `<a href="{{url "user.show" "id" .}}">user</a>`)
+	r := server.Router
+	r.Get("/users/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.TemplateHTML("link", req.Params().Int64("id"))
+	}).SetName("user.show")
+	url := server.Run()
+
+	resp, err := http.Get(url + "/users/12")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `<a href="/users/12">user</a>`, string(b))
+	require.Panics(t, func() {
+		r.Bind(http.MethodGet, "/members/{id}", wine.HandleResponder(wine.OK)).SetName("user.show")
+	})
+}
+
+func TestServer_Mount(t *testing.T) {
+	server := wine.NewTestServer(t)
+	r := server.Use(func(ctx context.Context, req *wine.Request) wine.Responder {
+		ctx = ctxutil.WithUserID(ctx, 42)
+		return wine.WithHeader(wine.Next(ctx, req), http.Header{"X-Mounted": {"1"}})
+	})
+	r.Group("v1").Mount("raw", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		require.Equal(t, int64(42), ctxutil.GetUserID(req.Context()))
+		_, ok := req.Context().Deadline()
+		require.True(t, ok)
+		b, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		fmt.Fprintf(w, "%s %s%s", req.URL.Path, req.URL.RawPath, b)
+	}))
+	sub := wine.NewServer(nil)
+	sub.RedirectTrailingSlash = true
+	sub.Get("/items/{id:int}", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, fmt.Sprint(req.Params().Int64("id")))
+	})
+	r.Mount("/sub", sub)
+	url := server.Run()
+
+	get := func(t *testing.T, path string) string {
+		resp, err := http.Get(url + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "1", resp.Header.Get("X-Mounted"))
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(b)
+	}
+	require.Equal(t, "/a/b/ ", get(t, "/v1/raw/a/b/"))
+	require.Equal(t, "/a/b%2Fc /a%2Fb%252Fc", get(t, "/v1/raw/a%2Fb%252Fc"))
+	require.Equal(t, "/ ", get(t, "/v1/raw"))
+	require.Equal(t, "12", get(t, "/sub/items/12"))
+
+	t.Run("Body", func(t *testing.T) {
+		resp, err := http.Post(url+"/v1/raw/a", "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "/a hello", string(b))
+	})
+
+	t.Run("Redirect", func(t *testing.T) {
+		c := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		resp, err := c.Get(url + "/sub/items/12/?a=1")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+		require.Equal(t, "/sub/items/12?a=1", resp.Header.Get("Location"))
+	})
+
+	resp, err := http.Get(url + "/_wine/endpoints")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(b), "/sub/items/{id:int}")
+}
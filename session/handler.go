@@ -2,12 +2,17 @@ package session
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gopub/errors"
+	"github.com/gopub/log/v2"
 	"github.com/gopub/wine"
 )
 
@@ -15,25 +20,33 @@ func NewHandler(provider Provider, options *Options) wine.HandlerFunc {
 	if options == nil {
 		options = DefaultOptions()
 	}
+	sources := options.IDSources
+	if sources == 0 {
+		sources = FromCookie | FromHeader
+	}
 
 	cookieIDKey := options.Name + "id"
 	headerIDKey := "X-" + strings.ToUpper(cookieIDKey[0:1]) + cookieIDKey[1:]
 
 	return func(ctx context.Context, req *wine.Request) wine.Responder {
-		sid := req.Params().String(cookieIDKey)
+		sid := readID(req.Request(), sources, cookieIDKey, headerIDKey)
+		if sid != "" && options.SigningKey != nil {
+			sid = verifyID(sid, options.SigningKey)
+		}
 		var ses Session
 		var err error
-		if sid != "" {
+		if cs, ok := provider.(ClientStore); ok {
+			ses, err = cs.Load(ctx, req.Request())
+		} else if sid != "" {
 			ses, err = provider.Get(ctx, sid)
-			if err != nil && !errors.IsNotExist(err) {
-				return wine.Error(err)
-			}
-		} else {
-			sid = uuid.NewString()
+		}
+		if err != nil && !errors.IsNotExist(err) {
+			return wine.Error(err)
 		}
 
 		if ses == nil {
-			ses, err = provider.Create(ctx, sid, options.TTL)
+			// Never accept ids which don't exist, otherwise attackers can fixate them
+			ses, err = provider.Create(ctx, newID(), options.TTL)
 		} else {
 			err = ses.SetTTL(options.TTL)
 		}
@@ -42,23 +55,127 @@ func NewHandler(provider Provider, options *Options) wine.HandlerFunc {
 			return wine.Error(err)
 		}
 
-		ctx = withSession(ctx, ses)
-
-		cookie := &http.Cookie{
-			Name:     cookieIDKey,
-			Value:    sid,
-			Expires:  time.Now().Add(options.TTL),
-			Path:     options.CookiePath,
-			HttpOnly: options.CookieHttpOnly,
+		if ui, ok := provider.(UserIndex); ok {
+			if err = touch(ctx, ui, ses.ID(), req.Request()); err != nil {
+				return wine.Error(err)
+			}
 		}
 
+		st := &state{
+			provider: provider,
+			options:  options,
+			session:  ses,
+		}
+		ctx = withState(ctx, st)
 		resp := wine.Next(ctx, req)
 
 		return wine.Handle(req.Request(), http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
-			http.SetCookie(writer, cookie)
+			// Session may be regenerated by the next handlers
+			sid := st.session.ID()
+			if options.SigningKey != nil {
+				sid = signID(sid, options.SigningKey)
+			}
+			http.SetCookie(writer, newCookie(cookieIDKey, sid, options))
+			if cs, ok := provider.(ClientStore); ok {
+				if err := cs.Save(ctx, writer, st.session); err != nil {
+					log.FromContext(ctx).Errorf("Save session %s: %v", st.session.ID(), err)
+				}
+			}
 			// Write to Header in case cookie is disabled by some browsers
-			writer.Header().Set(headerIDKey, sid)
+			if sources&FromHeader != 0 {
+				writer.Header().Set(headerIDKey, sid)
+			}
 			resp.Respond(ctx, writer)
 		}))
 	}
 }
+
+func readID(r *http.Request, sources IDSource, cookieIDKey, headerIDKey string) string {
+	if sources&FromCookie != 0 {
+		if c, err := r.Cookie(cookieIDKey); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	if sources&FromHeader != 0 {
+		if v := r.Header.Get(headerIDKey); v != "" {
+			return v
+		}
+	}
+	if sources&FromQuery != 0 {
+		return r.URL.Query().Get(cookieIDKey)
+	}
+	return ""
+}
+
+// touchInterval is the min interval of updating LastSeenAt, which avoids saving metadata on every request
+const touchInterval = time.Minute
+
+// touch updates metadata of the session with the request
+func touch(ctx context.Context, ui UserIndex, id string, r *http.Request) error {
+	now := time.Now()
+	md, err := ui.GetMetadata(ctx, id)
+	if err != nil {
+		if !errors.IsNotExist(err) {
+			return err
+		}
+		md = &Metadata{ID: id, CreatedAt: now}
+	}
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+	if now.Sub(md.LastSeenAt) < touchInterval && md.IP == ip && md.UserAgent == r.UserAgent() {
+		return nil
+	}
+	md.LastSeenAt = now
+	md.IP = ip
+	md.UserAgent = r.UserAgent()
+	return ui.SaveMetadata(ctx, md)
+}
+
+func newCookie(name, value string, options *Options) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     options.CookiePath,
+		Domain:   options.CookieDomain,
+		HttpOnly: options.CookieHttpOnly,
+		Secure:   options.CookieSecure,
+		SameSite: options.CookieSameSite,
+	}
+	maxAge := options.CookieMaxAge
+	if maxAge <= 0 {
+		maxAge = options.TTL
+	}
+	c.Expires = time.Now().Add(maxAge)
+	c.MaxAge = int(maxAge / time.Second)
+	return c
+}
+
+func newID() string {
+	return uuid.NewString()
+}
+
+// signID returns id.signature
+func signID(id string, key []byte) string {
+	return id + "." + idSignature(id, key)
+}
+
+// verifyID returns the id if the signature is valid, otherwise returns empty string
+func verifyID(signed string, key []byte) string {
+	i := strings.LastIndexByte(signed, '.')
+	if i <= 0 {
+		return ""
+	}
+	id, sig := signed[:i], signed[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(idSignature(id, key))) {
+		return ""
+	}
+	return id
+}
+
+func idSignature(id string, key []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(id))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
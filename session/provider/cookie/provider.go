@@ -0,0 +1,250 @@
+package cookie
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/session"
+)
+
+const (
+	version byte = 1
+	// chunkSize keeps each cookie under the 4096 bytes limit of browsers, with room for name and attributes
+	chunkSize = 3800
+	maxChunks = 10
+)
+
+type Options struct {
+	// Name is the cookie name, chunks are named as name_1, name_2, ...
+	Name     string
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+	// Keys are AES keys of 16, 24 or 32 bytes. The first key encrypts sessions,
+	// and all keys decrypt sessions, so that old keys can be rotated out gradually.
+	Keys [][]byte
+}
+
+// Provider keeps session data in AES-GCM encrypted cookies, so that servers don't need shared storage.
+// As cookies are stateless, Delete and ChangeID can't revoke sessions which have been issued.
+type Provider struct {
+	options *Options
+	aeads   []cipher.AEAD
+}
+
+var _ session.Provider = (*Provider)(nil)
+var _ session.ClientStore = (*Provider)(nil)
+var _ session.IDChanger = (*Provider)(nil)
+
+func NewProvider(options *Options) (*Provider, error) {
+	if options == nil || len(options.Keys) == 0 {
+		return nil, errors.New("keys cannot be empty")
+	}
+	if options.Name == "" {
+		return nil, errors.New("name cannot be empty")
+	}
+	p := &Provider{options: options}
+	for i, k := range options.Keys {
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		p.aeads = append(p.aeads, aead)
+	}
+	return p, nil
+}
+
+// Get always returns errors.NotExist, as sessions can only be loaded from requests
+func (p *Provider) Get(ctx context.Context, id string) (session.Session, error) {
+	return nil, errors.NotExist
+}
+
+func (p *Provider) Create(ctx context.Context, id string, ttl time.Duration) (session.Session, error) {
+	return newSession(id, ttl), nil
+}
+
+// Delete does nothing, call Session.Clear to remove data in cookies
+func (p *Provider) Delete(ctx context.Context, id string) error {
+	return nil
+}
+
+func (p *Provider) ChangeID(ctx context.Context, oldID, newID string, ttl time.Duration) (session.Session, error) {
+	s := newSession(newID, ttl)
+	if v := session.Get(ctx); v != nil && v.ID() == oldID {
+		if old, ok := v.(*Session); ok {
+			old.mu.Lock()
+			for k, d := range old.data {
+				s.data[k] = d
+			}
+			s.chunks = old.chunks
+			old.mu.Unlock()
+		}
+	}
+	return s, nil
+}
+
+func (p *Provider) Load(ctx context.Context, r *http.Request) (session.Session, error) {
+	c, err := r.Cookie(p.options.Name)
+	if err != nil {
+		return nil, errors.NotExist
+	}
+	// The first cookie is count.chunk, so that stale chunks are ignored
+	i := strings.IndexByte(c.Value, '.')
+	if i <= 0 {
+		return nil, errors.NotExist
+	}
+	chunks, err := strconv.Atoi(c.Value[:i])
+	if err != nil || chunks <= 0 || chunks > maxChunks {
+		return nil, errors.NotExist
+	}
+	value := c.Value[i+1:]
+	for i := 1; i < chunks; i++ {
+		c, err = r.Cookie(p.chunkName(i))
+		if err != nil {
+			return nil, errors.NotExist
+		}
+		value += c.Value
+	}
+
+	b, err := p.decrypt(value)
+	if err != nil {
+		return nil, errors.NotExist
+	}
+	var pl payload
+	if err = json.Unmarshal(b, &pl); err != nil {
+		return nil, errors.NotExist
+	}
+	if time.Now().Unix() > pl.ExpiresAt {
+		return nil, errors.NotExist
+	}
+	s := newSession(pl.ID, time.Until(time.Unix(pl.ExpiresAt, 0)))
+	if pl.Data != nil {
+		s.data = pl.Data
+	}
+	s.chunks = chunks
+	return s, nil
+}
+
+func (p *Provider) Save(ctx context.Context, w http.ResponseWriter, s session.Session) error {
+	cs, ok := s.(*Session)
+	if !ok {
+		return fmt.Errorf("cannot save %T", s)
+	}
+	cs.mu.Lock()
+	pl := &payload{
+		ID:        cs.id,
+		ExpiresAt: time.Now().Add(cs.ttl).Unix(),
+		Data:      cs.data,
+	}
+	b, err := json.Marshal(pl)
+	prevChunks := cs.chunks
+	cs.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	value, err := p.encrypt(b)
+	if err != nil {
+		return err
+	}
+
+	n := (len(value) + chunkSize - 1) / chunkSize
+	if n > maxChunks {
+		return fmt.Errorf("session is too large: %d bytes", len(b))
+	}
+	for i := 0; i < n; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		v := value[i*chunkSize : end]
+		if i == 0 {
+			v = strconv.Itoa(n) + "." + v
+		}
+		c := p.newCookie(p.chunkName(i), v)
+		c.Expires = time.Unix(pl.ExpiresAt, 0)
+		c.MaxAge = int(cs.ttl / time.Second)
+		http.SetCookie(w, c)
+	}
+	// Delete chunks which are no longer used
+	for i := n; i < prevChunks; i++ {
+		c := p.newCookie(p.chunkName(i), "")
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+	return nil
+}
+
+func (p *Provider) chunkName(i int) string {
+	if i == 0 {
+		return p.options.Name
+	}
+	return p.options.Name + "_" + strconv.Itoa(i)
+}
+
+func (p *Provider) newCookie(name, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     p.options.Path,
+		Domain:   p.options.Domain,
+		Secure:   p.options.Secure,
+		HttpOnly: p.options.HttpOnly,
+		SameSite: p.options.SameSite,
+	}
+}
+
+// encrypt returns base64 encoded version|nonce|ciphertext. Cookie name is authenticated as additional data,
+// so that values can't be moved to other cookies.
+func (p *Provider) encrypt(plaintext []byte) (string, error) {
+	aead := p.aeads[0]
+	b := make([]byte, 1+aead.NonceSize(), 1+aead.NonceSize()+len(plaintext)+aead.Overhead())
+	b[0] = version
+	if _, err := rand.Read(b[1:]); err != nil {
+		return "", err
+	}
+	b = aead.Seal(b, b[1:], plaintext, []byte(p.options.Name))
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (p *Provider) decrypt(value string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 || b[0] != version {
+		return nil, errors.New("unsupported version")
+	}
+	b = b[1:]
+	for _, aead := range p.aeads {
+		if len(b) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := b[:aead.NonceSize()], b[aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(p.options.Name)); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, errors.New("cannot decrypt")
+}
+
+type payload struct {
+	ID        string                     `json:"id"`
+	ExpiresAt int64                      `json:"exp"`
+	Data      map[string]json.RawMessage `json:"data,omitempty"`
+}
@@ -0,0 +1,102 @@
+package cookie_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/session/provider/cookie"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldKey = []byte("0123456789abcdef")
+	newKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func save(t *testing.T, p *cookie.Provider, id string, values map[string]interface{}) []*http.Cookie {
+	ctx := context.Background()
+	s, err := p.Create(ctx, id, time.Minute)
+	require.NoError(t, err)
+	for k, v := range values {
+		require.NoError(t, s.Set(ctx, k, v))
+	}
+	w := httptest.NewRecorder()
+	require.NoError(t, p.Save(ctx, w, s))
+	return w.Result().Cookies()
+}
+
+func newRequest(cookies []*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+	p, err := cookie.NewProvider(&cookie.Options{Name: "ws", Keys: [][]byte{oldKey}})
+	require.NoError(t, err)
+	cookies := save(t, p, "1", map[string]interface{}{"user": "tom", "age": 18})
+	require.Len(t, cookies, 1)
+	require.NotContains(t, cookies[0].Value, "tom")
+
+	s, err := p.Load(ctx, newRequest(cookies))
+	require.NoError(t, err)
+	require.Equal(t, "1", s.ID())
+	var user string
+	var age int
+	require.NoError(t, s.Get(ctx, "user", &user))
+	require.NoError(t, s.Get(ctx, "age", &age))
+	require.Equal(t, "tom", user)
+	require.Equal(t, 18, age)
+	require.True(t, errors.IsNotExist(s.Get(ctx, "x", &user)))
+
+	t.Run("Tampered", func(t *testing.T) {
+		c := *cookies[0]
+		c.Value = c.Value[:len(c.Value)-2] + "AA"
+		_, err := p.Load(ctx, newRequest([]*http.Cookie{&c}))
+		require.True(t, errors.IsNotExist(err))
+	})
+
+	t.Run("KeyRotation", func(t *testing.T) {
+		rotated, err := cookie.NewProvider(&cookie.Options{Name: "ws", Keys: [][]byte{newKey, oldKey}})
+		require.NoError(t, err)
+		s, err := rotated.Load(ctx, newRequest(cookies))
+		require.NoError(t, err)
+		require.Equal(t, "1", s.ID())
+
+		newCookies := save(t, rotated, "2", nil)
+		_, err = p.Load(ctx, newRequest(newCookies))
+		require.True(t, errors.IsNotExist(err))
+	})
+
+	t.Run("Chunks", func(t *testing.T) {
+		large := strings.Repeat("abcdefghij", 1000)
+		cookies := save(t, p, "3", map[string]interface{}{"large": large})
+		require.Len(t, cookies, 4)
+		require.Equal(t, "ws_1", cookies[1].Name)
+		s, err := p.Load(ctx, newRequest(cookies))
+		require.NoError(t, err)
+		var v string
+		require.NoError(t, s.Get(ctx, "large", &v))
+		require.Equal(t, large, v)
+
+		// Unused chunks are deleted
+		require.NoError(t, s.Delete(ctx, "large"))
+		w := httptest.NewRecorder()
+		require.NoError(t, p.Save(ctx, w, s))
+		cookies = w.Result().Cookies()
+		require.Len(t, cookies, 4)
+		for _, c := range cookies[1:] {
+			require.True(t, c.MaxAge < 0)
+		}
+		_, err = p.Load(ctx, newRequest(cookies[:1]))
+		require.NoError(t, err)
+	})
+}
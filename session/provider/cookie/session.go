@@ -0,0 +1,77 @@
+package cookie
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/session"
+)
+
+// Session keeps values in JSON, which is encrypted into cookies by Provider.Save
+type Session struct {
+	mu   sync.Mutex
+	id   string
+	ttl  time.Duration
+	data map[string]json.RawMessage
+	// chunks is the number of cookies loaded from request
+	chunks int
+}
+
+var _ session.Session = (*Session)(nil)
+
+func newSession(id string, ttl time.Duration) *Session {
+	return &Session{
+		id:   id,
+		ttl:  ttl,
+		data: make(map[string]json.RawMessage),
+	}
+}
+
+func (s *Session) ID() string {
+	return s.id
+}
+
+func (s *Session) Set(ctx context.Context, name string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.data[name] = b
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Session) Get(ctx context.Context, name string, ptrValue interface{}) error {
+	s.mu.Lock()
+	b, ok := s.data[name]
+	s.mu.Unlock()
+	if !ok {
+		return errors.NotExist
+	}
+	return json.Unmarshal(b, ptrValue)
+}
+
+func (s *Session) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	delete(s.data, name)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Session) Clear() error {
+	s.mu.Lock()
+	s.data = make(map[string]json.RawMessage)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Session) SetTTL(ttl time.Duration) error {
+	s.mu.Lock()
+	s.ttl = ttl
+	s.mu.Unlock()
+	return nil
+}
@@ -2,6 +2,8 @@ package mem
 
 import (
 	"context"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/gopub/errors"
@@ -11,13 +13,20 @@ import (
 
 type Provider struct {
 	cache *cache.Cache
+
+	mu sync.Mutex
+	// users indexes session ids by user id
+	users map[int64]map[string]struct{}
 }
 
 var _ session.Provider = (*Provider)(nil)
+var _ session.IDChanger = (*Provider)(nil)
+var _ session.UserIndex = (*Provider)(nil)
 
 func NewProvider() *Provider {
 	p := new(Provider)
 	p.cache = cache.New(session.DefaultOptions().TTL, session.DefaultOptions().TTL*50)
+	p.users = make(map[int64]map[string]struct{})
 	return p
 }
 
@@ -42,3 +51,100 @@ func (p *Provider) Delete(ctx context.Context, id string) error {
 	p.cache.Delete(id)
 	return nil
 }
+
+func (p *Provider) ChangeID(ctx context.Context, oldID, newID string, ttl time.Duration) (session.Session, error) {
+	s := &Session{
+		id:          newID,
+		sharedCache: p.cache,
+	}
+	var md *session.Metadata
+	if v, ok := p.cache.Get(oldID); ok {
+		old := v.(*Session)
+		old.data.Range(func(key, value interface{}) bool {
+			s.data.Store(key, value)
+			return true
+		})
+		md = old.getMetadata()
+	}
+	p.cache.Set(newID, s, ttl)
+	p.cache.Delete(oldID)
+	if md != nil {
+		md.ID = newID
+		if err := p.SaveMetadata(ctx, md); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (p *Provider) GetMetadata(ctx context.Context, id string) (*session.Metadata, error) {
+	v, ok := p.cache.Get(id)
+	if !ok {
+		return nil, errors.NotExist
+	}
+	md := v.(*Session).getMetadata()
+	if md == nil {
+		return nil, errors.NotExist
+	}
+	return md, nil
+}
+
+func (p *Provider) SaveMetadata(ctx context.Context, md *session.Metadata) error {
+	v, ok := p.cache.Get(md.ID)
+	if !ok {
+		return errors.NotExist
+	}
+	v.(*Session).setMetadata(md)
+	if md.UserID != 0 {
+		p.mu.Lock()
+		ids := p.users[md.UserID]
+		if ids == nil {
+			ids = make(map[string]struct{})
+			p.users[md.UserID] = ids
+		}
+		ids[md.ID] = struct{}{}
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+func (p *Provider) ListByUser(ctx context.Context, userID int64) ([]*session.Metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var l []*session.Metadata
+	for id := range p.users[userID] {
+		// Remove expired or deleted sessions from index
+		v, ok := p.cache.Get(id)
+		if !ok {
+			delete(p.users[userID], id)
+			continue
+		}
+		md := v.(*Session).getMetadata()
+		if md == nil || md.UserID != userID {
+			delete(p.users[userID], id)
+			continue
+		}
+		l = append(l, md)
+	}
+	if len(p.users[userID]) == 0 {
+		delete(p.users, userID)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].LastSeenAt.After(l[j].LastSeenAt)
+	})
+	return l, nil
+}
+
+func (p *Provider) DeleteByUser(ctx context.Context, userID int64) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id := range p.users[userID] {
+		if v, ok := p.cache.Get(id); ok {
+			if md := v.(*Session).getMetadata(); md != nil && md.UserID == userID {
+				p.cache.Delete(id)
+			}
+		}
+	}
+	delete(p.users, userID)
+	return nil
+}
@@ -0,0 +1,86 @@
+package mem_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/session"
+	"github.com/gopub/wine/session/provider/mem"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider_UserIndex(t *testing.T) {
+	ctx := context.Background()
+	p := mem.NewProvider()
+	server := wine.NewTestServer(t)
+	r := server.Use(session.NewHandler(p, nil))
+	r.Post("/login", func(ctx context.Context, req *wine.Request) wine.Responder {
+		if _, err := session.Regenerate(ctx); err != nil {
+			return wine.Error(err)
+		}
+		if err := session.SetUser(ctx, 1); err != nil {
+			return wine.Error(err)
+		}
+		return wine.Text(http.StatusOK, session.Get(ctx).ID())
+	})
+	r.Get("/ping", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.OK
+	})
+	url := server.Run()
+
+	login := func(t *testing.T, userAgent string) string {
+		req, err := http.NewRequest(http.MethodPost, url+"/login", nil)
+		require.NoError(t, err)
+		req.Header.Set("User-Agent", userAgent)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		return resp.Header.Get("X-Wsessionid")
+	}
+	id1 := login(t, "phone")
+	id2 := login(t, "laptop")
+
+	l, err := p.ListByUser(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, l, 2)
+	require.Equal(t, id2, l[0].ID)
+	require.Equal(t, "laptop", l[0].UserAgent)
+	require.Equal(t, "127.0.0.1", l[0].IP)
+	require.Equal(t, id1, l[1].ID)
+	require.False(t, l[1].CreatedAt.IsZero())
+
+	t.Run("Touch", func(t *testing.T) {
+		ping := func(userAgent string) *session.Metadata {
+			req, err := http.NewRequest(http.MethodGet, url+"/ping", nil)
+			require.NoError(t, err)
+			req.Header.Set("User-Agent", userAgent)
+			req.Header.Set("X-Wsessionid", id2)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			md, err := p.GetMetadata(ctx, id2)
+			require.NoError(t, err)
+			return md
+		}
+		// LastSeenAt is updated at most once a minute
+		require.Equal(t, l[0].LastSeenAt, ping("laptop").LastSeenAt)
+		md := ping("tablet")
+		require.Equal(t, "tablet", md.UserAgent)
+		require.True(t, md.LastSeenAt.After(l[0].LastSeenAt))
+	})
+
+	require.NoError(t, p.Delete(ctx, id1))
+	l, err = p.ListByUser(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, l, 1)
+
+	require.NoError(t, p.DeleteByUser(ctx, 1))
+	_, err = p.Get(ctx, id2)
+	require.Error(t, err)
+	l, err = p.ListByUser(ctx, 1)
+	require.NoError(t, err)
+	require.Empty(t, l)
+}
@@ -16,6 +16,9 @@ type Session struct {
 	id   string
 	data sync.Map
 
+	mu sync.Mutex
+	md *session.Metadata
+
 	sharedCache *cache.Cache
 }
 
@@ -71,3 +74,21 @@ func (m *Session) Clear() error {
 }
 
 var _ session.Session = (*Session)(nil)
+
+// getMetadata returns a copy of metadata
+func (m *Session) getMetadata() *session.Metadata {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.md == nil {
+		return nil
+	}
+	md := *m.md
+	return &md
+}
+
+func (m *Session) setMetadata(md *session.Metadata) {
+	v := *md
+	m.mu.Lock()
+	m.md = &v
+	m.mu.Unlock()
+}
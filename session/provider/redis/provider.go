@@ -2,6 +2,9 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -14,6 +17,44 @@ type Provider struct {
 }
 
 var _ session.Provider = (*Provider)(nil)
+var _ session.IDChanger = (*Provider)(nil)
+var _ session.UserIndex = (*Provider)(nil)
+
+// metadataField is the hash field of session metadata, so that metadata expires with session
+const metadataField = "@md"
+
+// saveMetadataScript saves metadata only if session exists, otherwise the hash would be created without ttl
+var saveMetadataScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// changeIDScript renames session KEYS[1] to KEYS[2] and resets ttl in milliseconds.
+// It returns 0 if the old session doesn't exist, -1 if the new id is used by another session, otherwise 1
+var changeIDScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+if redis.call("RENAMENX", KEYS[1], KEYS[2]) == 0 then
+	return -1
+end
+redis.call("PEXPIRE", KEYS[2], ARGV[1])
+return 1
+`)
+
+// addUserSessionScript adds session ARGV[1] to user's set KEYS[1], and extends ttl of the set to session's ttl ARGV[2].
+// The set expires after the last session of the user
+var addUserSessionScript = redis.NewScript(`
+redis.call("SADD", KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl > 0 and redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`)
 
 func NewProvider(c *redis.Client) *Provider {
 	p := new(Provider)
@@ -55,3 +96,112 @@ func (p *Provider) Create(ctx context.Context, id string, ttl time.Duration) (se
 func (p *Provider) Delete(ctx context.Context, id string) error {
 	return p.c.WithContext(ctx).Del(id).Err()
 }
+
+func (p *Provider) ChangeID(ctx context.Context, oldID, newID string, ttl time.Duration) (session.Session, error) {
+	c := p.c.WithContext(ctx)
+	n, err := changeIDScript.Run(c, []string{oldID, newID}, ttl.Milliseconds()).Int()
+	if err != nil {
+		return nil, err
+	}
+	switch n {
+	case 0:
+		return p.Create(ctx, newID, ttl)
+	case -1:
+		return nil, errors.Conflict("session %s already exists", newID)
+	}
+	if md, err := p.GetMetadata(ctx, newID); err == nil {
+		md.ID = newID
+		if err = p.SaveMetadata(ctx, md); err != nil {
+			return nil, err
+		}
+	} else if !errors.IsNotExist(err) {
+		return nil, err
+	}
+	return &Session{
+		id: newID,
+		c:  p.c,
+	}, nil
+}
+
+func (p *Provider) GetMetadata(ctx context.Context, id string) (*session.Metadata, error) {
+	b, err := p.c.WithContext(ctx).HGet(id, metadataField).Bytes()
+	if err == redis.Nil {
+		return nil, errors.NotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	md := new(session.Metadata)
+	if err = json.Unmarshal(b, md); err != nil {
+		return nil, fmt.Errorf("unmarshal metadata: %w", err)
+	}
+	return md, nil
+}
+
+func (p *Provider) SaveMetadata(ctx context.Context, md *session.Metadata) error {
+	b, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	c := p.c.WithContext(ctx)
+	n, err := saveMetadataScript.Run(c, []string{md.ID}, metadataField, b).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.NotExist
+	}
+	if md.UserID == 0 {
+		return nil
+	}
+	ttl, err := c.PTTL(md.ID).Result()
+	if err != nil {
+		return err
+	}
+	return addUserSessionScript.Run(c, []string{userKey(md.UserID)}, md.ID, ttl.Milliseconds()).Err()
+}
+
+func (p *Provider) ListByUser(ctx context.Context, userID int64) ([]*session.Metadata, error) {
+	c := p.c.WithContext(ctx)
+	ids, err := c.SMembers(userKey(userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var l []*session.Metadata
+	for _, id := range ids {
+		md, err := p.GetMetadata(ctx, id)
+		if err != nil && !errors.IsNotExist(err) {
+			return nil, err
+		}
+		// Remove expired or deleted sessions from index
+		if md == nil || md.UserID != userID {
+			if err = c.SRem(userKey(userID), id).Err(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		l = append(l, md)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].LastSeenAt.After(l[j].LastSeenAt)
+	})
+	return l, nil
+}
+
+func (p *Provider) DeleteByUser(ctx context.Context, userID int64) error {
+	l, err := p.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	c := p.c.WithContext(ctx)
+	for _, md := range l {
+		if err = c.Del(md.ID).Err(); err != nil {
+			return err
+		}
+	}
+	return c.Del(userKey(userID)).Err()
+}
+
+func userKey(userID int64) string {
+	return fmt.Sprintf("wine:session:user:%d", userID)
+}
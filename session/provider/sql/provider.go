@@ -0,0 +1,259 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/log/v2"
+	"github.com/gopub/wine/session"
+)
+
+// Dialect decides sql syntax of the database
+type Dialect int
+
+const (
+	SQLite Dialect = iota
+	Postgres
+	MySQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	case MySQL:
+		return "mysql"
+	default:
+		return fmt.Sprintf("dialect(%d)", int(d))
+	}
+}
+
+var logger = log.Default()
+
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type Options struct {
+	Dialect Dialect
+	// Table is the name of sessions table, and values are stored in Table_value. Default value is wine_session
+	Table string
+}
+
+// Provider stores sessions in two tables: one row per session with expiry time, and one row per value in JSON
+type Provider struct {
+	db           *sql.DB
+	dialect      Dialect
+	sessionTable string
+	valueTable   string
+}
+
+var _ session.Provider = (*Provider)(nil)
+var _ session.IDChanger = (*Provider)(nil)
+
+func NewProvider(db *sql.DB, options *Options) *Provider {
+	if options == nil {
+		options = &Options{}
+	}
+	table := options.Table
+	if table == "" {
+		table = "wine_session"
+	}
+	if !tableNameRegexp.MatchString(table) {
+		logger.Panicf("Invalid table name: %s", table)
+	}
+	return &Provider{
+		db:           db,
+		dialect:      options.Dialect,
+		sessionTable: table,
+		valueTable:   table + "_value",
+	}
+}
+
+// CreateTables creates tables if they don't exist
+func (p *Provider) CreateTables(ctx context.Context) error {
+	var stmts []string
+	switch p.dialect {
+	case MySQL:
+		stmts = []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+id VARCHAR(64) NOT NULL PRIMARY KEY,
+expires_at BIGINT NOT NULL,
+INDEX (expires_at))`, p.sessionTable),
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+session_id VARCHAR(64) NOT NULL,
+name VARCHAR(255) NOT NULL,
+value TEXT NOT NULL,
+PRIMARY KEY (session_id, name))`, p.valueTable),
+		}
+	default:
+		stmts = []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+id VARCHAR(64) NOT NULL PRIMARY KEY,
+expires_at BIGINT NOT NULL)`, p.sessionTable),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s_expires_at ON %s (expires_at)`, p.sessionTable, p.sessionTable),
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+session_id VARCHAR(64) NOT NULL,
+name VARCHAR(255) NOT NULL,
+value TEXT NOT NULL,
+PRIMARY KEY (session_id, name))`, p.valueTable),
+		}
+	}
+	for _, s := range stmts {
+		if _, err := p.db.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Provider) Get(ctx context.Context, id string) (session.Session, error) {
+	var n int
+	err := p.db.QueryRowContext(ctx, p.rebind(fmt.Sprintf("SELECT 1 FROM %s WHERE id=? AND expires_at>?", p.sessionTable)),
+		id, time.Now().Unix()).Scan(&n)
+	if err == sql.ErrNoRows {
+		return nil, errors.NotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Session{id: id, p: p}, nil
+}
+
+// Create creates an empty session, and the session with the same id is replaced
+func (p *Provider) Create(ctx context.Context, id string, ttl time.Duration) (session.Session, error) {
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("DELETE FROM %s WHERE session_id=?", p.valueTable)), id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, p.upsertSession(), id, time.Now().Add(ttl).Unix())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Session{id: id, p: p}, nil
+}
+
+func (p *Provider) Delete(ctx context.Context, id string) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("DELETE FROM %s WHERE session_id=?", p.valueTable)), id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("DELETE FROM %s WHERE id=?", p.sessionTable)), id)
+		return err
+	})
+}
+
+func (p *Provider) ChangeID(ctx context.Context, oldID, newID string, ttl time.Duration) (session.Session, error) {
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, p.upsertSession(), newID, time.Now().Add(ttl).Unix()); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("UPDATE %s SET session_id=? WHERE session_id=?", p.valueTable)),
+			newID, oldID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("DELETE FROM %s WHERE id=?", p.sessionTable)), oldID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Session{id: newID, p: p}, nil
+}
+
+// Sweep deletes expired sessions, and returns the number of deleted sessions
+func (p *Provider) Sweep(ctx context.Context) (int64, error) {
+	var n int64
+	now := time.Now().Unix()
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("DELETE FROM %s WHERE session_id IN (SELECT id FROM %s WHERE expires_at<=?)",
+			p.valueTable, p.sessionTable)), now)
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, p.rebind(fmt.Sprintf("DELETE FROM %s WHERE expires_at<=?", p.sessionTable)), now)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// StartJanitor sweeps expired sessions every interval until ctx is done
+func (p *Provider) StartJanitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := p.Sweep(ctx)
+				if err != nil {
+					log.FromContext(ctx).Errorf("Sweep expired sessions: %v", err)
+				} else if n > 0 {
+					log.FromContext(ctx).Debugf("Swept %d expired sessions", n)
+				}
+			}
+		}
+	}()
+}
+
+func (p *Provider) upsertSession() string {
+	if p.dialect == MySQL {
+		return fmt.Sprintf("INSERT INTO %s (id, expires_at) VALUES (?, ?) ON DUPLICATE KEY UPDATE expires_at=VALUES(expires_at)",
+			p.sessionTable)
+	}
+	return p.rebind(fmt.Sprintf("INSERT INTO %s (id, expires_at) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET expires_at=excluded.expires_at",
+		p.sessionTable))
+}
+
+func (p *Provider) upsertValue() string {
+	if p.dialect == MySQL {
+		return fmt.Sprintf("INSERT INTO %s (session_id, name, value) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE value=VALUES(value)",
+			p.valueTable)
+	}
+	return p.rebind(fmt.Sprintf("INSERT INTO %s (session_id, name, value) VALUES (?, ?, ?) ON CONFLICT (session_id, name) DO UPDATE SET value=excluded.value",
+		p.valueTable))
+}
+
+// rebind replaces ? with $1, $2... for postgres
+func (p *Provider) rebind(query string) string {
+	if p.dialect != Postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func (p *Provider) inTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = f(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,97 @@
+package sql_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gopub/errors"
+	sqlprovider "github.com/gopub/wine/session/provider/sql"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func newProvider(t *testing.T) *sqlprovider.Provider {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "session.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Close()
+	})
+	p := sqlprovider.NewProvider(db, &sqlprovider.Options{Dialect: sqlprovider.SQLite})
+	require.NoError(t, p.CreateTables(context.Background()))
+	// Tables can be created repeatedly
+	require.NoError(t, p.CreateTables(context.Background()))
+	return p
+}
+
+func TestProvider(t *testing.T) {
+	ctx := context.Background()
+	p := newProvider(t)
+
+	_, err := p.Get(ctx, "1")
+	require.True(t, errors.IsNotExist(err))
+
+	s, err := p.Create(ctx, "1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, s.Set(ctx, "user", map[string]interface{}{"name": "tom"}))
+	require.NoError(t, s.Set(ctx, "age", 18))
+	require.NoError(t, s.Set(ctx, "age", 19))
+
+	s, err = p.Get(ctx, "1")
+	require.NoError(t, err)
+	var user struct {
+		Name string `json:"name"`
+	}
+	var age int
+	require.NoError(t, s.Get(ctx, "user", &user))
+	require.NoError(t, s.Get(ctx, "age", &age))
+	require.Equal(t, "tom", user.Name)
+	require.Equal(t, 19, age)
+	require.True(t, errors.IsNotExist(s.Get(ctx, "x", &age)))
+
+	require.NoError(t, s.Delete(ctx, "age"))
+	require.True(t, errors.IsNotExist(s.Get(ctx, "age", &age)))
+
+	t.Run("ChangeID", func(t *testing.T) {
+		s, err := p.ChangeID(ctx, "1", "2", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, "2", s.ID())
+		require.NoError(t, s.Get(ctx, "user", &user))
+		_, err = p.Get(ctx, "1")
+		require.True(t, errors.IsNotExist(err))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		s, err := p.Get(ctx, "2")
+		require.NoError(t, err)
+		require.NoError(t, s.Clear())
+		require.True(t, errors.IsNotExist(s.Get(ctx, "user", &user)))
+		require.NoError(t, p.Delete(ctx, "2"))
+		_, err = p.Get(ctx, "2")
+		require.True(t, errors.IsNotExist(err))
+		// Values are not saved after session is deleted
+		require.True(t, errors.IsNotExist(s.Set(ctx, "user", user)))
+	})
+}
+
+func TestProvider_Sweep(t *testing.T) {
+	ctx := context.Background()
+	p := newProvider(t)
+	s, err := p.Create(ctx, "1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, s.Set(ctx, "k", "v"))
+	s, err = p.Create(ctx, "2", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, s.SetTTL(-time.Second))
+
+	_, err = p.Get(ctx, "2")
+	require.True(t, errors.IsNotExist(err))
+
+	n, err := p.Sweep(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	_, err = p.Get(ctx, "1")
+	require.NoError(t, err)
+}
@@ -0,0 +1,77 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine/session"
+)
+
+type Session struct {
+	id string
+	p  *Provider
+}
+
+var _ session.Session = (*Session)(nil)
+
+func (s *Session) ID() string {
+	return s.id
+}
+
+func (s *Session) Set(ctx context.Context, name string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", name, err)
+	}
+	// Values can't be saved after the session is deleted or swept, otherwise they would never be removed
+	return s.p.inTx(ctx, func(tx *sql.Tx) error {
+		var n int
+		err := tx.QueryRowContext(ctx, s.p.rebind(fmt.Sprintf("SELECT 1 FROM %s WHERE id=? AND expires_at>?", s.p.sessionTable)),
+			s.id, time.Now().Unix()).Scan(&n)
+		if err == sql.ErrNoRows {
+			return errors.NotExist
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, s.p.upsertValue(), s.id, name, string(b))
+		return err
+	})
+}
+
+func (s *Session) Get(ctx context.Context, name string, ptrValue interface{}) error {
+	if ptrValue == nil {
+		return errors.New("ptrValue is nil")
+	}
+	var v string
+	err := s.p.db.QueryRowContext(ctx, s.p.rebind(fmt.Sprintf("SELECT value FROM %s WHERE session_id=? AND name=?", s.p.valueTable)),
+		s.id, name).Scan(&v)
+	if err == sql.ErrNoRows {
+		return errors.NotExist
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(v), ptrValue)
+}
+
+func (s *Session) Delete(ctx context.Context, name string) error {
+	_, err := s.p.db.ExecContext(ctx, s.p.rebind(fmt.Sprintf("DELETE FROM %s WHERE session_id=? AND name=?", s.p.valueTable)),
+		s.id, name)
+	return err
+}
+
+func (s *Session) Clear() error {
+	_, err := s.p.db.Exec(s.p.rebind(fmt.Sprintf("DELETE FROM %s WHERE session_id=?", s.p.valueTable)), s.id)
+	return err
+}
+
+func (s *Session) SetTTL(ttl time.Duration) error {
+	_, err := s.p.db.Exec(s.p.rebind(fmt.Sprintf("UPDATE %s SET expires_at=? WHERE id=?", s.p.sessionTable)),
+		time.Now().Add(ttl).Unix(), s.id)
+	return err
+}
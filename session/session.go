@@ -2,10 +2,23 @@ package session
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gopub/environ"
+	"github.com/gopub/errors"
+)
+
+// IDSource is a bit set of request parts which session id can be read from
+type IDSource int
+
+const (
+	FromCookie IDSource = 1 << iota
+	FromHeader
+	// FromQuery allows id in url, which makes session fixation easy. It's not recommended.
+	FromQuery
 )
 
 type Options struct {
@@ -13,6 +26,15 @@ type Options struct {
 	TTL            time.Duration `json:"ttl,omitempty"`
 	CookiePath     string        `json:"cookie_path,omitempty"`
 	CookieHttpOnly bool          `json:"cookie_http_only,omitempty"`
+	CookieSecure   bool          `json:"cookie_secure,omitempty"`
+	CookieSameSite http.SameSite `json:"cookie_same_site,omitempty"`
+	CookieDomain   string        `json:"cookie_domain,omitempty"`
+	// CookieMaxAge is the lifetime of cookie, TTL is used if it's zero
+	CookieMaxAge time.Duration `json:"cookie_max_age,omitempty"`
+	// SigningKey signs session ids with HMAC-SHA256, ids with invalid signatures are ignored
+	SigningKey []byte `json:"-"`
+	// IDSources restricts where session id is read from, default value is FromCookie|FromHeader
+	IDSources IDSource `json:"id_sources,omitempty"`
 }
 
 var defaultOptions *Options
@@ -26,6 +48,13 @@ func DefaultOptions() *Options {
 		TTL:            environ.Duration("wine.session.ttl", 30*time.Minute),
 		CookieHttpOnly: true,
 		CookiePath:     "/",
+		CookieSecure:   environ.Bool("wine.session.cookie_secure", false),
+		CookieSameSite: http.SameSiteLaxMode,
+		CookieDomain:   environ.String("wine.session.cookie_domain", ""),
+		IDSources:      FromCookie | FromHeader,
+	}
+	if key := environ.String("wine.session.signing_key", ""); key != "" {
+		o.SigningKey = []byte(key)
 	}
 	o.Name = strings.ToLower(strings.TrimSpace(o.Name))
 	if o.Name == "" {
@@ -54,13 +83,42 @@ const (
 	keySession contextKey = iota + 1
 )
 
+// state is shared by handler and Regenerate, so that the new session is visible to both
+type state struct {
+	provider Provider
+	options  *Options
+	session  Session
+}
+
 func Get(ctx context.Context) Session {
-	v, _ := ctx.Value(keySession).(Session)
-	return v
+	if st, ok := ctx.Value(keySession).(*state); ok {
+		return st.session
+	}
+	return nil
+}
+
+func withState(ctx context.Context, st *state) context.Context {
+	return context.WithValue(ctx, keySession, st)
 }
 
-func withSession(ctx context.Context, s Session) context.Context {
-	return context.WithValue(ctx, keySession, s)
+// Regenerate issues a new session id and moves data to it, and the old id becomes invalid.
+// It should be called after login or privilege changes to prevent session fixation.
+// Provider must implement IDChanger.
+func Regenerate(ctx context.Context) (Session, error) {
+	st, ok := ctx.Value(keySession).(*state)
+	if !ok {
+		return nil, errors.New("session is missing")
+	}
+	c, ok := st.provider.(IDChanger)
+	if !ok {
+		return nil, fmt.Errorf("provider %T cannot change session id", st.provider)
+	}
+	ses, err := c.ChangeID(ctx, st.session.ID(), newID(), st.options.TTL)
+	if err != nil {
+		return nil, err
+	}
+	st.session = ses
+	return ses, nil
 }
 
 type Provider interface {
@@ -68,3 +126,60 @@ type Provider interface {
 	Create(ctx context.Context, id string, ttl time.Duration) (Session, error)
 	Delete(ctx context.Context, id string) error
 }
+
+// ClientStore is implemented by providers which keep sessions in clients instead of servers, e.g. provider/cookie.
+// Sessions are loaded from requests instead of Provider.Get, and saved to responses before they are written.
+type ClientStore interface {
+	// Load returns errors.NotExist if there is no valid session in the request
+	Load(ctx context.Context, r *http.Request) (Session, error)
+	Save(ctx context.Context, w http.ResponseWriter, s Session) error
+}
+
+// Metadata describes a session, e.g. for users to review and revoke their sessions on other devices
+type Metadata struct {
+	ID         string    `json:"id"`
+	UserID     int64     `json:"user_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+}
+
+// UserIndex is implemented by providers which keep metadata of sessions and index them by user id.
+// Handler updates metadata with requests, at most once a minute unless ip or user agent changes,
+// and SetUser associates the session with user.
+type UserIndex interface {
+	// GetMetadata returns errors.NotExist if session or its metadata doesn't exist
+	GetMetadata(ctx context.Context, id string) (*Metadata, error)
+	// SaveMetadata saves metadata, and indexes the session by md.UserID if it's not zero
+	SaveMetadata(ctx context.Context, md *Metadata) error
+	// ListByUser returns metadata of alive sessions of user, the most recently seen first
+	ListByUser(ctx context.Context, userID int64) ([]*Metadata, error)
+	// DeleteByUser deletes all sessions of user, e.g. log out of all devices
+	DeleteByUser(ctx context.Context, userID int64) error
+}
+
+// SetUser associates the session with user after login, so that it can be found by UserIndex.ListByUser.
+// Provider must implement UserIndex.
+func SetUser(ctx context.Context, userID int64) error {
+	st, ok := ctx.Value(keySession).(*state)
+	if !ok {
+		return errors.New("session is missing")
+	}
+	ui, ok := st.provider.(UserIndex)
+	if !ok {
+		return fmt.Errorf("provider %T cannot index sessions by user", st.provider)
+	}
+	md, err := ui.GetMetadata(ctx, st.session.ID())
+	if err != nil {
+		return err
+	}
+	md.UserID = userID
+	return ui.SaveMetadata(ctx, md)
+}
+
+// IDChanger is implemented by providers which can move session data to a new id
+type IDChanger interface {
+	// ChangeID moves data and metadata of oldID to newID, and deletes oldID
+	ChangeID(ctx context.Context, oldID, newID string, ttl time.Duration) (Session, error)
+}
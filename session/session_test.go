@@ -0,0 +1,228 @@
+package session_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopub/errors"
+	"github.com/gopub/wine"
+	"github.com/gopub/wine/session"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandler(t *testing.T) {
+	p := newProvider()
+	server := wine.NewTestServer(t)
+	r := server.Use(session.NewHandler(p, &session.Options{
+		Name:           "test",
+		TTL:            time.Minute,
+		CookiePath:     "/",
+		CookieHttpOnly: true,
+		CookieSecure:   true,
+		CookieSameSite: http.SameSiteStrictMode,
+		CookieDomain:   "localhost",
+		CookieMaxAge:   time.Hour,
+		SigningKey:     []byte("secret"),
+		IDSources:      session.FromCookie,
+	}))
+	r.Get("/id", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, session.Get(ctx).ID())
+	})
+	r.Post("/login", func(ctx context.Context, req *wine.Request) wine.Responder {
+		if err := session.Get(ctx).Set(ctx, "user", "tom"); err != nil {
+			return wine.Error(err)
+		}
+		ses, err := session.Regenerate(ctx)
+		if err != nil {
+			return wine.Error(err)
+		}
+		return wine.Text(http.StatusOK, ses.ID())
+	})
+	url := server.Run()
+
+	do := func(t *testing.T, method, path string, cookie *http.Cookie) (*http.Cookie, string) {
+		req, err := http.NewRequest(method, url+path, nil)
+		require.NoError(t, err)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		cookies := resp.Cookies()
+		require.Len(t, cookies, 1)
+		return cookies[0], string(b)
+	}
+
+	c, id := do(t, http.MethodGet, "/id", nil)
+	require.Equal(t, "testid", c.Name)
+	require.True(t, strings.HasPrefix(c.Value, id+"."))
+	require.True(t, c.Secure)
+	require.True(t, c.HttpOnly)
+	require.Equal(t, http.SameSiteStrictMode, c.SameSite)
+	require.Equal(t, 3600, c.MaxAge)
+
+	t.Run("Signed", func(t *testing.T) {
+		_, id2 := do(t, http.MethodGet, "/id", c)
+		require.Equal(t, id, id2)
+		_, id2 = do(t, http.MethodGet, "/id", &http.Cookie{Name: c.Name, Value: id})
+		require.NotEqual(t, id, id2)
+		_, id2 = do(t, http.MethodGet, "/id", &http.Cookie{Name: c.Name, Value: id + ".x"})
+		require.NotEqual(t, id, id2)
+	})
+
+	t.Run("Sources", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url+"/id?testid="+c.Value, nil)
+		require.NoError(t, err)
+		req.Header.Set("X-Testid", c.Value)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NotEqual(t, id, string(b))
+		require.Empty(t, resp.Header.Get("X-Testid"))
+	})
+
+	t.Run("Regenerate", func(t *testing.T) {
+		nc, newID := do(t, http.MethodPost, "/login", c)
+		require.NotEqual(t, id, newID)
+		require.True(t, strings.HasPrefix(nc.Value, newID+"."))
+		ses, err := p.Get(context.Background(), newID)
+		require.NoError(t, err)
+		var user string
+		require.NoError(t, ses.Get(context.Background(), "user", &user))
+		require.Equal(t, "tom", user)
+		_, err = p.Get(context.Background(), id)
+		require.True(t, errors.IsNotExist(err))
+	})
+}
+
+func TestNewHandler_Fixation(t *testing.T) {
+	server := wine.NewTestServer(t)
+	server.Use(session.NewHandler(newProvider(), &session.Options{
+		Name:       "test",
+		TTL:        time.Minute,
+		CookiePath: "/",
+	})).Get("/id", func(ctx context.Context, req *wine.Request) wine.Responder {
+		return wine.Text(http.StatusOK, session.Get(ctx).ID())
+	})
+	url := server.Run()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+	req, err := http.NewRequest(http.MethodGet, url+"/id", nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: "testid", Value: "chosen-by-attacker"})
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NotEqual(t, "chosen-by-attacker", string(b))
+	require.Equal(t, string(b), resp.Header.Get("X-Testid"))
+}
+
+type provider struct {
+	mu       sync.Mutex
+	sessions map[string]*memSession
+}
+
+var _ session.IDChanger = (*provider)(nil)
+
+func newProvider() *provider {
+	return &provider{sessions: make(map[string]*memSession)}
+}
+
+func (p *provider) Get(ctx context.Context, id string) (session.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sessions[id]
+	if !ok {
+		return nil, errors.NotExist
+	}
+	return s, nil
+}
+
+func (p *provider) Create(ctx context.Context, id string, ttl time.Duration) (session.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := &memSession{id: id, data: make(map[string]string)}
+	p.sessions[id] = s
+	return s, nil
+}
+
+func (p *provider) Delete(ctx context.Context, id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sessions, id)
+	return nil
+}
+
+func (p *provider) ChangeID(ctx context.Context, oldID, newID string, ttl time.Duration) (session.Session, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s := p.sessions[oldID]
+	delete(p.sessions, oldID)
+	s.id = newID
+	p.sessions[newID] = s
+	return s, nil
+}
+
+type memSession struct {
+	mu   sync.Mutex
+	id   string
+	data map[string]string
+}
+
+func (s *memSession) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+func (s *memSession) Set(ctx context.Context, name string, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[name] = value.(string)
+	return nil
+}
+
+func (s *memSession) Get(ctx context.Context, name string, ptrValue interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.data[name]
+	if !ok {
+		return errors.NotExist
+	}
+	*ptrValue.(*string) = v
+	return nil
+}
+
+func (s *memSession) Delete(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, name)
+	return nil
+}
+
+func (s *memSession) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = make(map[string]string)
+	return nil
+}
+
+func (s *memSession) SetTTL(ttl time.Duration) error {
+	return nil
+}
@@ -0,0 +1,160 @@
+package wine
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultCertCheckInterval = 10 * time.Second
+
+// CertificateReloader loads certificate from files and reloads it once the files are changed.
+// Set its GetCertificate to tls.Config.GetCertificate in order to rotate certificates without restarting server.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	// CheckInterval is the min interval of checking files' modification time
+	CheckInterval time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	r := &CertificateReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		CheckInterval: defaultCertCheckInterval,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload loads certificate from files
+func (r *CertificateReloader) Reload() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load x509 key pair: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate can be assigned to tls.Config.GetCertificate
+func (r *CertificateReloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	cert, modTime, checkedAt := r.cert, r.modTime, r.checkedAt
+	r.mu.RUnlock()
+	if time.Since(checkedAt) < r.CheckInterval {
+		return cert, nil
+	}
+
+	r.mu.Lock()
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	t, err := r.latestModTime()
+	if err != nil {
+		logger.Errorf("Cannot check certificate files: %v", err)
+		return cert, nil
+	}
+	if !t.After(modTime) {
+		return cert, nil
+	}
+	// Keep serving with the old certificate if the new one is invalid, e.g. files are being written
+	if err = r.Reload(); err != nil {
+		logger.Errorf("Cannot reload certificate: %v", err)
+		return cert, nil
+	}
+	logger.Infof("Reloaded certificate %s", r.certFile)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertificateReloader) latestModTime() (time.Time, error) {
+	var t time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return t, fmt.Errorf("stat %s: %w", name, err)
+		}
+		if fi.ModTime().After(t) {
+			t = fi.ModTime()
+		}
+	}
+	return t, nil
+}
+
+// newTestCertificate creates a self-signed CA and issues a certificate for localhost with it
+func newTestCertificate() (*tls.Certificate, *x509.CertPool, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate ca key: %w", err)
+	}
+	now := time.Now()
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"Wine Test CA"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create ca certificate: %w", err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse ca certificate: %w", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("generate key: %w", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{Organization: []string{"Wine Test"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("create certificate: %w", err)
+	}
+	cert := &tls.Certificate{
+		Certificate: [][]byte{der, caDER},
+		PrivateKey:  key,
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return cert, pool, nil
+}
@@ -0,0 +1,56 @@
+package wine
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificate(t *testing.T, certFile, keyFile string) *tls.Certificate {
+	cert, _, err := newTestCertificate()
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	require.NoError(t, ioutil.WriteFile(certFile, certPEM, 0600))
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	require.NoError(t, ioutil.WriteFile(keyFile, keyPEM, 0600))
+	return cert
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	old := writeTestCertificate(t, certFile, keyFile)
+
+	r, err := NewCertificateReloader(certFile, keyFile)
+	require.NoError(t, err)
+	r.CheckInterval = 0
+	c, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, old.Certificate[0], c.Certificate[0])
+
+	// Make sure modification time is changed
+	time.Sleep(10 * time.Millisecond)
+	cur := writeTestCertificate(t, certFile, keyFile)
+	c, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cur.Certificate[0], c.Certificate[0])
+
+	// Keep the current certificate if new files are invalid
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("invalid"), 0600))
+	c, err = r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cur.Certificate[0], c.Certificate[0])
+}
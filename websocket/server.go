@@ -98,6 +98,9 @@ type Server struct {
 // Server implements http.Handler in order to take over http conn and upgrade to websocket conn
 var _ http.Handler = (*Server)(nil)
 
+// Server lists its routes while mounted by wine.Router.Mount
+var _ wine.RouteLister = (*Server)(nil)
+
 func NewServer() *Server {
 	s := &Server{
 		Router:      NewRouter(),